@@ -0,0 +1,873 @@
+package fsutil
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ModePolicy determines how file modes stored in an archive
+// are applied to the files and directories being extracted.
+type ModePolicy int
+
+const (
+	// ModeSanitize is the default policy. Setuid, setgid and sticky
+	// bits are removed and the owner is always granted read/write
+	// access (plus execute/search access on directories), so
+	// extracted content is never unusable.
+	ModeSanitize ModePolicy = iota
+
+	// ModeKeep applies the archived permission bits as-is, including
+	// setuid, setgid and sticky bits.
+	ModeKeep
+
+	// ModeUmask applies the archived permission bits, masked by the
+	// umask of the current process (like tar does for regular users).
+	ModeUmask
+
+	// ModeStripSpecial applies the archived permission bits after
+	// removing setuid, setgid and sticky bits.
+	ModeStripSpecial
+
+	// ModeFixed ignores the archived modes entirely and applies
+	// the FileMode/DirMode of the extraction options.
+	ModeFixed
+)
+
+const (
+	defaultFileMode os.FileMode = 0644
+	defaultDirMode  os.FileMode = 0755
+	specialModeBits             = os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+)
+
+// UnzipOptions configures the behavior of Unzip and Untar.
+type UnzipOptions struct {
+	// Mode determines how archived file modes are applied.
+	// Defaults to ModeSanitize.
+	Mode ModePolicy
+
+	// FileMode is applied to files when Mode is ModeFixed.
+	// Defaults to 0644.
+	FileMode os.FileMode
+
+	// DirMode is applied to directories when Mode is ModeFixed,
+	// as well as to any parent directory that is not explicitly
+	// part of the archive. Defaults to 0755.
+	DirMode os.FileMode
+
+	// Include restricts extraction to entries matching at least one
+	// of the glob patterns. Patterns without a slash are also matched
+	// against the base name of each entry (e.g. "*.json").
+	Include []string
+
+	// Exclude skips entries matching any of the glob patterns.
+	Exclude []string
+
+	// Filter is an optional predicate applied after Include/Exclude.
+	// Entries are only extracted when it returns true.
+	Filter func(name string, info fs.FileInfo) bool
+
+	// MaxSize limits the total number of bytes written during
+	// extraction (protecting against decompression bombs).
+	// Zero means unlimited.
+	MaxSize int64
+
+	// PreserveOwner applies the user/group IDs stored in the archive
+	// (tar only). This generally requires elevated privileges and is
+	// ignored on Windows.
+	PreserveOwner bool
+
+	// Symlinks materializes the symlinks of zip archives, provided their
+	// targets remain inside the destination. Otherwise symlinks are skipped.
+	// The symlinks of tar archives are always restored, with the same checks.
+	Symlinks bool
+
+	// Password decrypts WinZip AES encrypted entries (zip only).
+	// Extracting an encrypted entry without it fails with
+	// ErrPasswordRequired, or ErrInvalidPassword when it is wrong.
+	Password string
+}
+
+var (
+	// ErrEntryNotFound is returned when a named entry does not exist in an archive.
+	ErrEntryNotFound = errors.New("entry not found in archive")
+
+	// ErrArchiveTooLarge is returned when extraction exceeds the MaxSize option.
+	ErrArchiveTooLarge = errors.New("archive exceeds the maximum extraction size")
+)
+
+func (opts *UnzipOptions) fileMode() os.FileMode {
+	if opts.FileMode == 0 {
+		return defaultFileMode
+	}
+	return opts.FileMode
+}
+
+func (opts *UnzipOptions) dirMode() os.FileMode {
+	if opts.DirMode == 0 {
+		return defaultDirMode
+	}
+	return opts.DirMode
+}
+
+// selected determines whether an archive entry should be extracted.
+func (opts *UnzipOptions) selected(name string, info fs.FileInfo) (bool, error) {
+	if len(opts.Include) > 0 {
+		matched, err := matchEntry(name, opts.Include...)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+
+	if len(opts.Exclude) > 0 {
+		matched, err := matchEntry(name, opts.Exclude...)
+		if err != nil || matched {
+			return false, err
+		}
+	}
+
+	if opts.Filter != nil {
+		return opts.Filter(name, info), nil
+	}
+
+	return true, nil
+}
+
+// matchEntry determines whether a slash-separated archive entry name
+// matches any of the glob patterns.
+func matchEntry(name string, patterns ...string) (bool, error) {
+	name = strings.TrimSuffix(name, "/")
+
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(filepath.ToSlash(pattern), "/")
+
+		matched, err := path.Match(pattern, name)
+		if err != nil {
+			return false, err
+		}
+
+		if !matched && !strings.Contains(pattern, "/") {
+			matched, _ = path.Match(pattern, path.Base(name))
+		}
+
+		if matched {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// resolveMode applies the mode policy to the mode of an archive entry.
+func (opts *UnzipOptions) resolveMode(mode os.FileMode, dir bool) os.FileMode {
+	perm := mode & (os.ModePerm | specialModeBits)
+
+	switch opts.Mode {
+	case ModeKeep:
+		return perm
+	case ModeUmask:
+		return perm &^ umask()
+	case ModeStripSpecial:
+		return perm &^ specialModeBits
+	case ModeFixed:
+		if dir {
+			return opts.dirMode()
+		}
+		return opts.fileMode()
+	default:
+		if dir {
+			return perm&^specialModeBits | 0700
+		}
+		return perm&^specialModeBits | 0600
+	}
+}
+
+// extractor writes archive entries beneath a destination directory.
+// Directory modes and times are applied once extraction completes, so
+// restrictive directory permissions cannot prevent their own content
+// from being written.
+type extractor struct {
+	dest    string
+	opts    *UnzipOptions
+	dirs    map[string]extractedDir
+	written int64
+}
+
+type extractedDir struct {
+	mode    os.FileMode
+	modtime time.Time
+}
+
+func newExtractor(dest string, opts *UnzipOptions) *extractor {
+	return &extractor{
+		dest: filepath.Clean(dest),
+		opts: opts,
+		dirs: make(map[string]extractedDir),
+	}
+}
+
+// target resolves the destination path of an archive entry,
+// preventing ZipSlip (directory traversal).
+func (x *extractor) target(name string) (string, error) {
+	path := filepath.Join(x.dest, name)
+
+	if path == x.dest || !x.inside(path) {
+		return "", fmt.Errorf("illegal file path: %s", path)
+	}
+
+	return path, nil
+}
+
+// prepare creates the parent directories of an entry beneath the
+// destination without following symlinks, so a symlink planted in the
+// destination (or extracted from the archive) cannot redirect writes
+// outside of it. Any existing file at the path itself is removed, rather
+// than written through (it may be a symlink or a hard link). It returns
+// the path relative to the destination.
+//
+// The checks are made by path, so files and directories are then created
+// with openBeneath and mkdirBeneath, which (on Linux) open each parent
+// directory relative to the previous one and refuse symlinks, in case a
+// directory is concurrently replaced. Elsewhere, and for links, a
+// concurrent process with write access to the destination can still
+// redirect the entry between the check and its creation.
+func (x *extractor) prepare(path string) (string, error) {
+	rel, err := filepath.Rel(x.dest, path)
+	if err != nil {
+		return "", err
+	}
+
+	parts := strings.Split(rel, string(os.PathSeparator))
+	current := x.dest
+
+	for i, part := range parts[:len(parts)-1] {
+		current = filepath.Join(current, part)
+
+		info, err := os.Lstat(current)
+		switch {
+		case os.IsNotExist(err):
+			if err = mkdirBeneath(x.dest, filepath.Join(parts[:i+1]...), x.opts.dirMode()); err != nil {
+				return "", err
+			}
+		case err != nil:
+			return "", err
+		case info.Mode()&os.ModeSymlink != 0:
+			return "", fmt.Errorf("illegal file path: %s traverses the symlink %s", path, current)
+		case !info.IsDir():
+			return "", fmt.Errorf("illegal file path: %s is not a directory", current)
+		}
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil || info.IsDir() {
+		return rel, nil
+	}
+
+	return rel, os.Remove(path)
+}
+
+func (x *extractor) mkdir(path string, mode os.FileMode, modtime time.Time) error {
+	rel, err := x.prepare(path)
+	if err != nil {
+		return err
+	}
+
+	if err = mkdirBeneath(x.dest, rel, x.opts.dirMode()); err != nil && !os.IsExist(err) {
+		return err
+	}
+
+	x.dirs[path] = extractedDir{
+		mode:    x.opts.resolveMode(mode, true),
+		modtime: modtime,
+	}
+
+	return nil
+}
+
+func (x *extractor) writeFile(path string, mode os.FileMode, r io.Reader) (err error) {
+	rel, err := x.prepare(path)
+	if err != nil {
+		return err
+	}
+
+	mode = x.opts.resolveMode(mode, false)
+	f, err := openBeneath(x.dest, rel, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	defer closeWithError(f, &err)
+
+	if err = x.copy(f, r); err != nil {
+		return err
+	}
+
+	// OpenFile is subject to the umask.
+	return f.Chmod(mode)
+}
+
+// symlink creates a symbolic link, provided its target
+// resolves to a location inside the destination.
+func (x *extractor) symlink(linkname string, path string) error {
+	if _, err := x.prepare(path); err != nil {
+		return err
+	}
+
+	if _, err := x.resolveLink(filepath.Dir(path), linkname, 0); err != nil {
+		return fmt.Errorf("illegal link target: %s -> %s (%w)", path, linkname, err)
+	}
+
+	return os.Symlink(linkname, path)
+}
+
+// link creates a hard link to a previously extracted file.
+func (x *extractor) link(original string, path string) error {
+	if _, err := x.prepare(path); err != nil {
+		return err
+	}
+
+	resolved, err := x.resolveLink(x.dest, original, 0)
+	if err != nil {
+		return fmt.Errorf("illegal link target: %s -> %s (%w)", path, original, err)
+	}
+
+	return os.Link(resolved, path)
+}
+
+// maxLinkLength limits the length of a symlink target read from a zip archive.
+const maxLinkLength = 4096
+
+// maxLinkDepth limits the number of symlinks followed when resolving a link.
+const maxLinkDepth = 40
+
+// resolveLink resolves a link target relative to dir one path component
+// at a time, following any symlinks along the way. Every step must remain
+// inside the destination. Targets that do not exist (yet) are allowed.
+func (x *extractor) resolveLink(dir string, linkname string, depth int) (string, error) {
+	if depth > maxLinkDepth {
+		return "", errors.New("too many levels of symbolic links")
+	}
+
+	if filepath.IsAbs(linkname) {
+		rel, err := filepath.Rel(x.dest, filepath.Clean(linkname))
+		if err != nil {
+			return "", err
+		}
+
+		dir, linkname = x.dest, rel
+	}
+
+	current := dir
+	for _, part := range strings.Split(filepath.FromSlash(linkname), string(os.PathSeparator)) {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			current = filepath.Dir(current)
+		default:
+			current = filepath.Join(current, part)
+		}
+
+		if !x.inside(current) {
+			return "", errors.New("target is outside of the destination")
+		}
+
+		info, err := os.Lstat(current)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+
+		target, err := os.Readlink(current)
+		if err != nil {
+			return "", err
+		}
+
+		if current, err = x.resolveLink(filepath.Dir(current), target, depth+1); err != nil {
+			return "", err
+		}
+	}
+
+	return current, nil
+}
+
+func (x *extractor) inside(path string) bool {
+	return path == x.dest || strings.HasPrefix(path, x.dest+string(os.PathSeparator))
+}
+
+// copy writes the content of an entry, enforcing the MaxSize limit.
+func (x *extractor) copy(w io.Writer, r io.Reader) error {
+	if x.opts.MaxSize <= 0 {
+		_, err := io.Copy(w, r)
+		return err
+	}
+
+	n, err := io.Copy(w, io.LimitReader(r, x.opts.MaxSize-x.written+1))
+	x.written += n
+	if err != nil {
+		return err
+	}
+
+	if x.written > x.opts.MaxSize {
+		return ErrArchiveTooLarge
+	}
+
+	return nil
+}
+
+// chown applies the archived owner when PreserveOwner is enabled.
+func (x *extractor) chown(path string, uid int, gid int) error {
+	if !x.opts.PreserveOwner || runtime.GOOS == "windows" {
+		return nil
+	}
+
+	return os.Lchown(path, uid, gid)
+}
+
+// finish applies the deferred directory modes and times, deepest directories first.
+func (x *extractor) finish() error {
+	paths := make([]string, 0, len(x.dirs))
+	for path := range x.dirs {
+		paths = append(paths, path)
+	}
+
+	sort.Slice(paths, func(i, j int) bool {
+		return len(paths[i]) > len(paths[j])
+	})
+
+	for _, path := range paths {
+		dir := x.dirs[path]
+
+		if !dir.modtime.IsZero() {
+			if err := os.Chtimes(path, dir.modtime, dir.modtime); err != nil {
+				return err
+			}
+		}
+
+		if err := os.Chmod(path, dir.mode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func unzip(r *zip.Reader, dest string, opts *UnzipOptions) error {
+	if err := os.MkdirAll(dest, opts.dirMode()); err != nil {
+		return err
+	}
+
+	x := newExtractor(dest, opts)
+
+	for _, f := range r.File {
+		ok, err := opts.selected(f.Name, f.FileInfo())
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			continue
+		}
+
+		if err := x.extractZipFile(f); err != nil {
+			return err
+		}
+	}
+
+	return x.finish()
+}
+
+func (x *extractor) extractZipFile(f *zip.File) (err error) {
+	path, err := x.target(f.Name)
+	if err != nil {
+		return err
+	}
+
+	if f.FileInfo().IsDir() {
+		return x.mkdir(path, f.Mode(), time.Time{})
+	}
+
+	rc, err := openZipFile(f, x.opts.Password)
+	if err != nil {
+		return err
+	}
+	defer closeWithError(rc, &err)
+
+	if f.Mode()&os.ModeSymlink != 0 {
+		if !x.opts.Symlinks {
+			return nil
+		}
+
+		// The content of a symlink entry is its target.
+		linkname, err := io.ReadAll(io.LimitReader(rc, maxLinkLength))
+		if err != nil {
+			return err
+		}
+
+		return x.symlink(string(linkname), path)
+	}
+
+	return x.writeFile(path, f.Mode(), rc)
+}
+
+// ExtractFile extracts a single named entry from a zip archive to the
+// dest file path. If dest is an existing directory, the entry is written
+// inside it using the base name of the entry. An optional UnzipOptions
+// argument controls how the archived file mode is applied.
+func ExtractFile(archive string, entryName string, dest string, options ...UnzipOptions) (err error) {
+	opts := UnzipOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	r, err := openZip(Abs(archive))
+	if err != nil {
+		return err
+	}
+	defer closeWithError(r, &err)
+
+	f, err := findZipFile(r.Reader, entryName)
+	if err != nil {
+		return err
+	}
+
+	if f.FileInfo().IsDir() {
+		return fmt.Errorf("%s is a directory", entryName)
+	}
+
+	dest = Abs(dest)
+	if IsDirectory(dest) {
+		dest = filepath.Join(dest, path.Base(f.Name))
+	}
+
+	rc, err := openZipFile(f, opts.Password)
+	if err != nil {
+		return err
+	}
+	defer closeWithError(rc, &err)
+
+	return newExtractor(filepath.Dir(dest), &opts).writeFile(dest, f.Mode(), rc)
+}
+
+// OpenInArchive opens a single named entry of a zip archive for reading,
+// without writing anything to disk. The caller must close the returned
+// reader, which also closes the archive. An optional password decrypts
+// AES encrypted entries.
+func OpenInArchive(archive string, entryName string, password ...string) (io.ReadCloser, error) {
+	r, err := openZip(Abs(archive))
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := findZipFile(r.Reader, entryName)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	pwd := ""
+	if len(password) > 0 {
+		pwd = password[0]
+	}
+
+	rc, err := openZipFile(f, pwd)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	return &archiveEntryReader{ReadCloser: rc, archive: r}, nil
+}
+
+// archiveEntryReader closes the parent archive along with the entry.
+type archiveEntryReader struct {
+	io.ReadCloser
+	archive io.Closer
+}
+
+func (r *archiveEntryReader) Close() error {
+	err := r.ReadCloser.Close()
+	closeWithError(r.archive, &err)
+	return err
+}
+
+func findZipFile(r *zip.Reader, entryName string) (*zip.File, error) {
+	entryName = strings.TrimPrefix(filepath.ToSlash(entryName), "./")
+
+	for _, f := range r.File {
+		if f.Name == entryName || strings.TrimSuffix(f.Name, "/") == entryName {
+			return f, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrEntryNotFound, entryName)
+}
+
+// ArchiveEntry describes a single entry of an archive.
+type ArchiveEntry struct {
+	// Name is the slash-separated path of the entry within the archive.
+	Name string
+
+	// Size is the uncompressed size in bytes.
+	Size int64
+
+	// CompressedSize is the size of the compressed data in bytes.
+	CompressedSize int64
+
+	Mode     os.FileMode
+	Modified time.Time
+
+	// CRC32 is the checksum of the uncompressed data
+	// (zero for AES encrypted entries).
+	CRC32 uint32
+
+	// Method is the compression method (i.e. zip.Store or zip.Deflate).
+	// Encrypted entries report the AES method (99).
+	Method uint16
+
+	// Encrypted determines whether the content of the entry is encrypted.
+	Encrypted bool
+}
+
+// IsDir determines whether the entry represents a directory.
+func (e ArchiveEntry) IsDir() bool {
+	return e.Mode.IsDir()
+}
+
+// ArchiveList lists the contents of a zip archive without extracting it.
+func ArchiveList(archive string) (entries []ArchiveEntry, err error) {
+	r, err := openZip(Abs(archive))
+	if err != nil {
+		return nil, err
+	}
+	defer closeWithError(r, &err)
+
+	entries = make([]ArchiveEntry, len(r.File))
+	for i, f := range r.File {
+		entries[i] = ArchiveEntry{
+			Name:           f.Name,
+			Size:           int64(f.UncompressedSize64),
+			CompressedSize: int64(f.CompressedSize64),
+			Mode:           f.Mode(),
+			Modified:       f.Modified,
+			CRC32:          f.CRC32,
+			Method:         f.Method,
+			Encrypted:      f.Flags&flagEncrypted != 0,
+		}
+	}
+
+	return entries, nil
+}
+
+// ArchiveVerify tests the integrity of a zip archive by decompressing
+// every entry and verifying its checksum, without writing any output.
+// The first corrupt entry is reported in the error. An optional password
+// decrypts (and authenticates) AES encrypted entries.
+func ArchiveVerify(archive string, password ...string) (err error) {
+	r, err := openZip(Abs(archive))
+	if err != nil {
+		return err
+	}
+	defer closeWithError(r, &err)
+
+	pwd := ""
+	if len(password) > 0 {
+		pwd = password[0]
+	}
+
+	for _, f := range r.File {
+		if err = verifyZipFile(f, pwd); err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func verifyZipFile(f *zip.File, password string) (err error) {
+	rc, err := openZipFile(f, password)
+	if err != nil {
+		return err
+	}
+	defer closeWithError(rc, &err)
+
+	_, err = io.Copy(io.Discard, rc)
+	return err
+}
+
+// ArchiveFormat identifies the format of an archive.
+type ArchiveFormat int
+
+// Archive formats recognized by DetectArchiveFormat.
+const (
+	FormatUnknown ArchiveFormat = iota
+	FormatZip
+	FormatTar
+	FormatGzip
+	FormatBzip2
+)
+
+// ErrUnsupportedFormat is returned when an archive format cannot be determined.
+var ErrUnsupportedFormat = errors.New("unsupported archive format")
+
+var (
+	zipMagic      = []byte("PK\x03\x04")
+	zipEmptyMagic = []byte("PK\x05\x06")
+	tarMagic      = []byte("ustar")
+)
+
+// tarMagicOffset is the position of the magic field in a tar header.
+const tarMagicOffset = 257
+
+func (f ArchiveFormat) String() string {
+	switch f {
+	case FormatZip:
+		return "zip"
+	case FormatTar:
+		return "tar"
+	case FormatGzip:
+		return "gzip"
+	case FormatBzip2:
+		return "bzip2"
+	default:
+		return "unknown"
+	}
+}
+
+// DetectArchiveFormat identifies the format of an archive from its magic bytes.
+func DetectArchiveFormat(path string) (format ArchiveFormat, err error) {
+	file, err := os.Open(Abs(path))
+	if err != nil {
+		return FormatUnknown, err
+	}
+	defer closeWithError(file, &err)
+
+	header := make([]byte, tarMagicOffset+len(tarMagic))
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		if err == io.EOF {
+			return FormatUnknown, nil
+		}
+		return FormatUnknown, err
+	}
+
+	format = detectFormat(header[:n])
+
+	// The last volume of a split zip archive starts mid-data.
+	if format == FormatUnknown {
+		if info, err := file.Stat(); err == nil {
+			if d, err := readDirectoryEnd(file, info.Size()); err == nil && d.disk > 0 {
+				format = FormatZip
+			}
+		}
+	}
+
+	return format, nil
+}
+
+func detectFormat(header []byte) ArchiveFormat {
+	switch {
+	case bytes.HasPrefix(header, zipMagic), bytes.HasPrefix(header, zipEmptyMagic):
+		return FormatZip
+	case bytes.HasPrefix(header, gzipMagic):
+		return FormatGzip
+	case bytes.HasPrefix(header, bzip2Magic):
+		return FormatBzip2
+	case len(header) >= tarMagicOffset+len(tarMagic) && bytes.Equal(header[tarMagicOffset:], tarMagic):
+		return FormatTar
+	default:
+		return FormatUnknown
+	}
+}
+
+// Extract an archive into the destination directory. The format is
+// detected from the magic bytes of the source: zip archives are
+// extracted with Unzip, while tar archives (plain, gzip or bzip2
+// compressed) are extracted with Untar. A single compressed file that
+// is not a tar archive (i.e. `app.log.gz`) returns ErrUnsupportedFormat;
+// use Gunzip to decompress it.
+func Extract(src string, dest string, options ...UnzipOptions) error {
+	format, err := DetectArchiveFormat(src)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatZip:
+		return Unzip(src, dest, options...)
+	case FormatGzip, FormatBzip2:
+		ok, err := compressedTar(src)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return fmt.Errorf("%w: %s is not a tar archive", ErrUnsupportedFormat, src)
+		}
+
+		return Untar(src, dest, options...)
+	case FormatTar:
+		return Untar(src, dest, options...)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, src)
+	}
+}
+
+// compressedTar determines whether a gzip or bzip2 file contains
+// a tar archive, rather than a single compressed file.
+func compressedTar(path string) (ok bool, err error) {
+	file, err := os.Open(Abs(path))
+	if err != nil {
+		return false, err
+	}
+	defer closeWithError(file, &err)
+
+	r, err := decompress(file)
+	if err != nil {
+		return false, err
+	}
+
+	header := make([]byte, tarMagicOffset+len(tarMagic))
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+
+	return detectFormat(header[:n]) == FormatTar, nil
+}
+
+// Archive a file or directory. The format is determined by the
+// destination extension: `.zip` archives are created with Zip,
+// while `.tar`, `.tar.gz` and `.tgz` archives are created with Tar.
+// bzip2 compressed tar archives (`.tar.bz2`, `.tbz2`, `.tbz`) can be
+// extracted, but not created (ErrUnsupportedCompression).
+func Archive(src string, dest string) error {
+	name := strings.ToLower(dest)
+
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return Zip(src, dest)
+	case strings.HasSuffix(name, ".tar.bz2"), strings.HasSuffix(name, ".tbz2"), strings.HasSuffix(name, ".tbz"):
+		return fmt.Errorf("%w: bzip2 archives cannot be written", ErrUnsupportedCompression)
+	case strings.HasSuffix(name, ".tar"), strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return Tar(src, dest)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, dest)
+	}
+}
+
+// closeWithError closes c, reporting the close error through err
+// unless an earlier error has already been recorded.
+func closeWithError(c io.Closer, err *error) {
+	if cerr := c.Close(); cerr != nil && *err == nil {
+		*err = cerr
+	}
+}
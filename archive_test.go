@@ -0,0 +1,541 @@
+package fsutil
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+type testZipEntry struct {
+	Name    string
+	Content string
+	Mode    os.FileMode
+}
+
+func writeTestZip(path string, entries ...testZipEntry) error {
+	Mkdirp(filepath.Dir(path))
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := zip.NewWriter(file)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.Name, Method: zip.Deflate}
+		if entry.Mode != 0 {
+			header.SetMode(entry.Mode)
+		}
+
+		w, err := writer.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		if _, err = w.Write([]byte(entry.Content)); err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+func TestUnzipModes(t *testing.T) {
+	clear()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not supported on Windows")
+	}
+
+	abs := Abs(testDir)
+	archive := filepath.Join(abs, "modes.zip")
+
+	err := writeTestZip(archive,
+		testZipEntry{Name: "locked/", Mode: os.ModeDir},
+		testZipEntry{Name: "locked/file.txt", Content: "test content", Mode: 0},
+		testZipEntry{Name: "setuid.sh", Content: "#!/bin/sh", Mode: 0755 | os.ModeSetuid},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Default (sanitized) modes
+	out := filepath.Join(abs, "sanitized")
+	err = Unzip(archive, out)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+
+	stat, err := os.Stat(filepath.Join(out, "setuid.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stat.Mode()&os.ModeSetuid != 0 {
+		t.Log("Setuid bit was not removed from extracted file.")
+		t.Fail()
+	}
+
+	stat, err = os.Stat(filepath.Join(out, "locked"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stat.Mode().Perm()&0700 != 0700 {
+		t.Logf("Expected owner access to extracted directory, received %v", stat.Mode())
+		t.Fail()
+	}
+
+	data, err := ReadTextFile(filepath.Join(out, "locked", "file.txt"))
+	if err != nil || data != "test content" {
+		t.Log("Extracted file in restricted directory is unreadable.")
+		t.Fail()
+	}
+
+	// Fixed modes
+	out = filepath.Join(abs, "fixed")
+	err = Unzip(archive, out, UnzipOptions{Mode: ModeFixed, FileMode: 0600, DirMode: 0750})
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+
+	stat, err = os.Stat(filepath.Join(out, "setuid.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stat.Mode() != 0600 {
+		t.Logf("Expected file mode 0600, received %v", stat.Mode())
+		t.Fail()
+	}
+
+	stat, err = os.Stat(filepath.Join(out, "locked"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stat.Mode().Perm() != 0750 {
+		t.Logf("Expected directory mode 0750, received %v", stat.Mode().Perm())
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestUnzipMissingSource(t *testing.T) {
+	clear()
+
+	err := Unzip(filepath.Join(testDir, "dne.zip"), filepath.Join(testDir, "out"))
+	if err == nil {
+		t.Log("Expected an error when unzipping a non-existent archive.")
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestUnzipSelective(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	archive := filepath.Join(abs, "bundle.zip")
+
+	err := writeTestZip(archive,
+		testZipEntry{Name: "config/app.json", Content: "{}"},
+		testZipEntry{Name: "config/app.yml", Content: "app:"},
+		testZipEntry{Name: "bin/app", Content: "binary"},
+		testZipEntry{Name: "README.md", Content: "readme"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(abs, "out")
+	err = Unzip(archive, out, UnzipOptions{
+		Include: []string{"config/*", "*.md"},
+		Exclude: []string{"*.yml"},
+	})
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+
+	files, _ := ListFiles(out, true)
+	if len(files) != 2 || !Exists(filepath.Join(out, "config", "app.json")) || !Exists(filepath.Join(out, "README.md")) {
+		t.Logf("Expected config/app.json and README.md, received %v", files)
+		t.Fail()
+	}
+
+	out = filepath.Join(abs, "filtered")
+	err = Unzip(archive, out, UnzipOptions{
+		Filter: func(name string, info fs.FileInfo) bool {
+			return info.Size() > 4
+		},
+	})
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+
+	files, _ = ListFiles(out, true)
+	if len(files) != 2 {
+		t.Logf("Expected 2 filtered files, received %v", files)
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestExtractFile(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	archive := filepath.Join(abs, "bundle.zip")
+
+	err := writeTestZip(archive,
+		testZipEntry{Name: "config/app.json", Content: "{}"},
+		testZipEntry{Name: "README.md", Content: "readme"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ExtractFile(archive, "config/app.json", filepath.Join(abs, "settings.json"))
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+
+	data, _ := ReadTextFile(filepath.Join(abs, "settings.json"))
+	if data != "{}" {
+		t.Logf("Expected extracted content \"{}\", received \"%v\"", data)
+		t.Fail()
+	}
+
+	// Extract into an existing directory
+	err = ExtractFile(archive, "README.md", Mkdirp(filepath.Join(abs, "docs")))
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+
+	if !IsFile(filepath.Join(abs, "docs", "README.md")) {
+		t.Log("Entry was not extracted into the destination directory.")
+		t.Fail()
+	}
+
+	err = ExtractFile(archive, "missing.txt", abs)
+	if !errors.Is(err, ErrEntryNotFound) {
+		t.Logf("Expected ErrEntryNotFound, received %v", err)
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestOpenInArchive(t *testing.T) {
+	clear()
+
+	archive := filepath.Join(Abs(testDir), "bundle.zip")
+
+	err := writeTestZip(archive, testZipEntry{Name: "config/app.json", Content: "{}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := OpenInArchive(archive, "config/app.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+
+	if err = r.Close(); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+
+	if string(data) != "{}" {
+		t.Logf("Expected \"{}\", received \"%v\"", string(data))
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestArchiveList(t *testing.T) {
+	clear()
+
+	archive := filepath.Join(Abs(testDir), "bundle.zip")
+
+	err := writeTestZip(archive,
+		testZipEntry{Name: "config/", Mode: os.ModeDir | 0755},
+		testZipEntry{Name: "config/app.json", Content: "{\"name\":\"test\"}", Mode: 0644},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ArchiveList(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, received %v", len(entries))
+	}
+
+	if !entries[0].IsDir() || entries[1].IsDir() {
+		t.Log("Directory entries were not identified correctly.")
+		t.Fail()
+	}
+
+	if entries[1].Name != "config/app.json" || entries[1].Size != 15 || entries[1].Method != zip.Deflate || entries[1].CRC32 == 0 {
+		t.Logf("Unexpected entry details: %+v", entries[1])
+		t.Fail()
+	}
+
+	if err = ArchiveVerify(archive); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestArchiveVerifyCorrupt(t *testing.T) {
+	clear()
+
+	archive := filepath.Join(Abs(testDir), "corrupt.zip")
+	Mkdirp(filepath.Dir(archive))
+
+	file, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer := zip.NewWriter(file)
+	w, _ := writer.CreateHeader(&zip.FileHeader{Name: "test.txt", Method: zip.Store})
+	w.Write([]byte("test content"))
+	writer.Close()
+	file.Close()
+
+	// Flip a byte of the stored (uncompressed) content.
+	data, _ := os.ReadFile(archive)
+	offset := strings.Index(string(data), "test content")
+	data[offset] = 'T'
+	os.WriteFile(archive, data, 0644)
+
+	if err = ArchiveVerify(archive); !errors.Is(err, zip.ErrChecksum) {
+		t.Logf("Expected a checksum error, received %v", err)
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestArchiveExtract(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	src := filepath.Join(abs, "src")
+	content := "test content"
+
+	err := WriteTextFile(filepath.Join(src, "more", "test.txt"), content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	formats := map[string]ArchiveFormat{
+		"test.zip":    FormatZip,
+		"test.tar":    FormatTar,
+		"test.tar.gz": FormatGzip,
+	}
+
+	for name, expected := range formats {
+		archive := filepath.Join(abs, name)
+
+		if err = Archive(src, archive); err != nil {
+			t.Fatal(err)
+		}
+
+		format, err := DetectArchiveFormat(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if format != expected {
+			t.Logf("%v: expected format %v, received %v", name, expected, format)
+			t.Fail()
+		}
+
+		out := filepath.Join(abs, "out-"+name)
+		if err = Extract(archive, out); err != nil {
+			t.Log(err.Error())
+			t.Fail()
+		}
+
+		data, _ := ReadTextFile(filepath.Join(out, "more", "test.txt"))
+		if data != content {
+			t.Logf("%v: extracted file contents do not match", name)
+			t.Fail()
+		}
+	}
+
+	if err = Archive(src, filepath.Join(abs, "test.rar")); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Logf("Expected ErrUnsupportedFormat, received %v", err)
+		t.Fail()
+	}
+
+	bzip2 := filepath.Join(abs, "test.tbz2")
+	if err = Archive(src, bzip2); !errors.Is(err, ErrUnsupportedCompression) || Exists(bzip2) {
+		t.Logf("Expected ErrUnsupportedCompression without creating the archive, received %v", err)
+		t.Fail()
+	}
+
+	if err = Extract(filepath.Join(src, "more", "test.txt"), filepath.Join(abs, "out")); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Logf("Expected ErrUnsupportedFormat, received %v", err)
+		t.Fail()
+	}
+
+	// A single gzipped file is not a tar archive.
+	gz, err := Gzip(filepath.Join(src, "more", "test.txt"), GzipOptions{Dest: filepath.Join(abs, "test.txt.gz")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = Extract(gz, filepath.Join(abs, "out")); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Logf("Expected ErrUnsupportedFormat, received %v", err)
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestUnzipSymlinks(t *testing.T) {
+	clear()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require elevated privileges on Windows")
+	}
+
+	abs := Abs(testDir)
+	outside := Mkdirp(filepath.Join(abs, "outside"))
+	out := Mkdirp(filepath.Join(abs, "out"))
+
+	// A symlink planted in the destination must not redirect writes.
+	if err := os.Symlink(outside, filepath.Join(out, "planted")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(filepath.Join(outside, "file.txt"), filepath.Join(out, "file.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(abs, "planted.zip")
+	err := writeTestZip(archive,
+		testZipEntry{Name: "planted/evil.txt", Content: "evil"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = Unzip(archive, out); err == nil {
+		t.Log("Expected extraction through a planted symlink to fail.")
+		t.Fail()
+	}
+
+	archive = filepath.Join(abs, "replace.zip")
+	if err = writeTestZip(archive, testZipEntry{Name: "file.txt", Content: "safe"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = Unzip(archive, out); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+
+	if Exists(filepath.Join(outside, "evil.txt")) || Exists(filepath.Join(outside, "file.txt")) {
+		t.Log("Extraction wrote outside of the destination.")
+		t.Fail()
+	}
+
+	// Archived symlinks
+	archive = filepath.Join(abs, "links.zip")
+	err = writeTestZip(archive,
+		testZipEntry{Name: "test.txt", Content: "test content"},
+		testZipEntry{Name: "inside", Content: "test.txt", Mode: os.ModeSymlink | 0777},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = Unzip(archive, filepath.Join(abs, "skipped")); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+
+	if _, err = os.Lstat(filepath.Join(abs, "skipped", "inside")); err == nil {
+		t.Log("Symlinks should be skipped unless enabled.")
+		t.Fail()
+	}
+
+	if err = Unzip(archive, filepath.Join(abs, "links"), UnzipOptions{Symlinks: true}); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+
+	if data, _ := ReadTextFile(filepath.Join(abs, "links", "inside")); data != "test content" {
+		t.Log("Archived symlink was not materialized.")
+		t.Fail()
+	}
+
+	archive = filepath.Join(abs, "escape.zip")
+	err = writeTestZip(archive,
+		testZipEntry{Name: "escape", Content: "../outside", Mode: os.ModeSymlink | 0777},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = Unzip(archive, filepath.Join(abs, "escape"), UnzipOptions{Symlinks: true}); err == nil {
+		t.Log("Expected a symlink escaping the destination to be rejected.")
+		t.Fail()
+	}
+
+	// A directory replaced by a symlink after it was checked
+	// is refused when the file is created (Linux only).
+	if runtime.GOOS == "linux" {
+		f, err := openBeneath(out, filepath.Join("planted", "raced.txt"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			f.Close()
+			t.Log("Expected a file beneath a symlinked directory to be refused.")
+			t.Fail()
+		}
+
+		if err = mkdirBeneath(out, filepath.Join("planted", "raced"), 0755); err == nil {
+			t.Log("Expected a directory beneath a symlinked directory to be refused.")
+			t.Fail()
+		}
+
+		if Exists(filepath.Join(outside, "raced.txt")) || Exists(filepath.Join(outside, "raced")) {
+			t.Log("Extraction wrote outside of the destination.")
+			t.Fail()
+		}
+	}
+
+	clear()
+}
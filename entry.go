@@ -0,0 +1,184 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// EntryType identifies the type of a file system entry.
+type EntryType int
+
+// Entry types.
+const (
+	EntryFile EntryType = iota
+	EntryDir
+	EntrySymlink
+	EntrySocket
+	EntryFIFO
+	EntryDevice
+	EntryOther
+)
+
+func (t EntryType) String() string {
+	switch t {
+	case EntryFile:
+		return "file"
+	case EntryDir:
+		return "dir"
+	case EntrySymlink:
+		return "symlink"
+	case EntrySocket:
+		return "socket"
+	case EntryFIFO:
+		return "fifo"
+	case EntryDevice:
+		return "device"
+	default:
+		return "other"
+	}
+}
+
+// entryType determines the type of a file mode.
+func entryType(mode os.FileMode) EntryType {
+	switch {
+	case mode.IsRegular():
+		return EntryFile
+	case mode.IsDir():
+		return EntryDir
+	case mode&os.ModeSymlink != 0:
+		return EntrySymlink
+	case mode&os.ModeSocket != 0:
+		return EntrySocket
+	case mode&os.ModeNamedPipe != 0:
+		return EntryFIFO
+	case mode&(os.ModeDevice|os.ModeCharDevice) != 0:
+		return EntryDevice
+	default:
+		return EntryOther
+	}
+}
+
+// Entry describes a file system entry found while listing a directory.
+type Entry struct {
+	// Path is the absolute path of the entry.
+	Path string
+
+	// RelPath is the path relative to the listed directory
+	// ("." for the directory itself).
+	RelPath string
+
+	// Name is the base name of the entry.
+	Name string
+
+	// Depth is the number of directories between the listed directory
+	// and the entry (0 for the directory itself, 1 for its contents).
+	Depth int
+
+	Size    int64
+	Mode    os.FileMode
+	ModTime time.Time
+	Type    EntryType
+
+	// Target is the destination of a symlink (as stored in the link).
+	// When symlinks are followed, the entry describes the destination
+	// and Target is still set.
+	Target string
+
+	// Broken indicates a symlink whose destination does not exist.
+	Broken bool
+
+	// Inode and Device identify the underlying file, where supported
+	// by the operating system (zero otherwise).
+	Inode  uint64
+	Device uint64
+}
+
+// IsDir determines whether the entry is a directory.
+func (e Entry) IsDir() bool {
+	return e.Type == EntryDir
+}
+
+// newEntry describes the path of a listing of root.
+func newEntry(root string, path string, info os.FileInfo) (Entry, error) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	entry := Entry{
+		Path:    path,
+		RelPath: rel,
+		Name:    info.Name(),
+		Size:    info.Size(),
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+		Type:    entryType(info.Mode()),
+		Depth:   listDepth(root, path),
+	}
+
+	if dev, ino, _, ok := fileID(info); ok {
+		entry.Device, entry.Inode = dev, ino
+	}
+
+	if _, followed := info.(followedLink); followed || entry.Type == EntrySymlink {
+		if entry.Target, err = os.Readlink(path); err != nil {
+			return entry, err
+		}
+	}
+
+	if entry.Type == EntrySymlink {
+		if _, err = os.Stat(path); err != nil {
+			entry.Broken = true
+		}
+	}
+
+	return entry, nil
+}
+
+// ListEntries lists the directory like List, describing each entry
+// with the metadata gathered during the walk, so results do not need to
+// be stat'ed again. Optionally provide ListOptions (see ListWith).
+// Unlike List, walk errors are returned.
+func ListEntries(directory string, recursive bool, options ...ListOptions) ([]Entry, error) {
+	entries := make([]Entry, 0)
+
+	opts := ListOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	config, err := newListConfig(opts)
+	if err != nil {
+		return entries, err
+	}
+
+	directory = Abs(directory)
+
+	err = walkList(directory, recursive, config, func(path string, info os.FileInfo) error {
+		entry, err := newEntry(directory, path, info)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, entry)
+		return nil
+	})
+
+	if err == nil && config.options.sorted() {
+		keys := make([]sortKey, len(entries))
+		for i := range entries {
+			keys[i] = sortKey{dir: entries[i].IsDir(), size: entries[i].Size, modtime: entries[i].ModTime}
+			if entries[i].RelPath != "." {
+				keys[i].parts = strings.Split(entries[i].RelPath, string(os.PathSeparator))
+			}
+		}
+
+		sortListing(keys, func(i, j int) {
+			entries[i], entries[j] = entries[j], entries[i]
+		}, &config.options)
+	}
+
+	return entries, err
+}
@@ -0,0 +1,70 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestListEntries(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	WriteTextFile(filepath.Join(abs, "more", "test.txt"), "test content")
+
+	if runtime.GOOS != "windows" {
+		os.Symlink("more/test.txt", filepath.Join(abs, "link"))
+	}
+
+	entries, err := ListEntries(abs, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := make(map[string]Entry)
+	for _, entry := range entries {
+		found[filepath.ToSlash(entry.RelPath)] = entry
+	}
+
+	if root := found["."]; root.Path != abs || root.Depth != 0 || !root.IsDir() {
+		t.Logf("Unexpected root entry %+v", root)
+		t.Fail()
+	}
+
+	file, ok := found["more/test.txt"]
+	if !ok {
+		t.Fatalf("Expected an entry for more/test.txt, received %v", entries)
+	}
+
+	if file.Name != "test.txt" || file.Depth != 2 || file.Size != 12 || file.Type != EntryFile {
+		t.Logf("Unexpected file entry %+v", file)
+		t.Fail()
+	}
+
+	info, _ := os.Stat(file.Path)
+	if !file.ModTime.Equal(info.ModTime()) {
+		t.Logf("Expected modification time %v, received %v", info.ModTime(), file.ModTime)
+		t.Fail()
+	}
+
+	if runtime.GOOS != "windows" {
+		if file.Inode == 0 {
+			t.Log("Expected the inode of the file")
+			t.Fail()
+		}
+
+		link := found["link"]
+		if link.Type != EntrySymlink || link.Target != "more/test.txt" {
+			t.Logf("Unexpected symlink entry %+v", link)
+			t.Fail()
+		}
+	}
+
+	if _, err = ListEntries("./dne", true); !os.IsNotExist(err) {
+		t.Logf("Expected a not exist error, received %v", err)
+		t.Fail()
+	}
+
+	clear()
+}
@@ -8,16 +8,11 @@ package fsutil
 // more easily understood code.
 
 import (
-	"archive/zip"
 	"errors"
 	"fmt"
-	"io"
-	"io/fs"
 	"io/ioutil"
-	"math"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -233,75 +228,327 @@ type listpath struct {
 	Stat os.FileInfo
 }
 
-func list(directory string, recursive bool, ignore ...string) ([]*listpath, error) {
-	directory = Abs(directory)
+// ListOptions configures the listings of ListWith, ListFilesWith,
+// ListDirectoriesWith, ListFunc and ListEntries. The zero value
+// lists everything.
+type ListOptions struct {
+	// Ignore excludes the paths matching glob patterns (see List).
+	Ignore []string
+
+	// IgnoreRules excludes the paths matching gitignore rules.
+	IgnoreRules *IgnoreRules
+
+	// MinDepth and MaxDepth limit the depth of the listed entries, where
+	// the listed directory has a depth of 0 and its contents a depth of 1.
+	// Directories at MaxDepth are not walked. A MaxDepth of zero is unlimited.
+	MinDepth int
+	MaxDepth int
+
+	// Types restricts the listing to entries of the given types.
+	Types []EntryType
+
+	// SkipHidden excludes hidden files and directories (whose names start
+	// with a dot, or which have the hidden attribute on Windows), along
+	// with the contents of hidden directories.
+	SkipHidden bool
+
+	// MinSize and MaxSize limit the size (in bytes) of listed files.
+	// Directories are not filtered by size. A MaxSize of zero is unlimited.
+	MinSize int64
+	MaxSize int64
+
+	// ModifiedAfter and ModifiedBefore limit the modification time of
+	// listed entries (exclusively). Zero times are unbounded.
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+
+	// Sort orders the entries of List, ListFiles, ListDirectories and
+	// ListEntries (entries are always streamed in walk order by ListFunc).
+	// Setting Descending or DirsFirst alone sorts entries by name.
+	Sort SortBy
+
+	// Descending reverses the sort order.
+	Descending bool
+
+	// DirsFirst lists directories before files. Recursive listings
+	// sorted by name list the subdirectories of each directory first.
+	DirsFirst bool
+
+	// WalkOptions determine whether symlinks are followed, in recursive
+	// and non-recursive listings alike, and whether recursive listings
+	// read directories concurrently.
+	WalkOptions
+}
+
+// selected determines whether an entry at the given depth is listed.
+func (opts *ListOptions) selected(path string, info os.FileInfo, depth int) bool {
+	if depth < opts.MinDepth || (opts.MaxDepth > 0 && depth > opts.MaxDepth) {
+		return false
+	}
+
+	if opts.SkipHidden && depth > 0 && isHidden(path, info) {
+		return false
+	}
+
+	filtered := len(opts.Types) > 0 || opts.MinSize > 0 || opts.MaxSize > 0 ||
+		!opts.ModifiedAfter.IsZero() || !opts.ModifiedBefore.IsZero()
+
+	if !filtered {
+		return true
+	}
+
+	// Entries that cannot be stat'ed cannot match the filters.
+	if info == nil {
+		return false
+	}
+
+	if len(opts.Types) > 0 {
+		matched := false
+		for _, t := range opts.Types {
+			if t == entryType(info.Mode()) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	if !info.IsDir() {
+		if info.Size() < opts.MinSize || (opts.MaxSize > 0 && info.Size() > opts.MaxSize) {
+			return false
+		}
+	}
+
+	if !opts.ModifiedAfter.IsZero() && !info.ModTime().After(opts.ModifiedAfter) {
+		return false
+	}
+
+	if !opts.ModifiedBefore.IsZero() && !info.ModTime().Before(opts.ModifiedBefore) {
+		return false
+	}
+
+	return true
+}
+
+// descend determines whether the contents of a directory
+// at the given depth are walked.
+func (opts *ListOptions) descend(path string, info os.FileInfo, depth int) bool {
+	if opts.MaxDepth > 0 && depth >= opts.MaxDepth {
+		return false
+	}
+
+	return !(opts.SkipHidden && depth > 0 && isHidden(path, info))
+}
+
+// listConfig holds the optional arguments of the List functions.
+type listConfig struct {
+	ignore  globSet
+	rules   *IgnoreRules
+	options ListOptions
+
+	// skipErrors skips the paths of recursive walks that cannot be read.
+	skipErrors bool
+}
+
+func newListConfig(options ListOptions) (*listConfig, error) {
+	ignore, err := compileGlobs(options.Ignore...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &listConfig{ignore: ignore, rules: options.IgnoreRules, options: options}, nil
+}
+
+func list(directory string, recursive bool, options ListOptions) ([]*listpath, error) {
 	response := make([]*listpath, 0)
-	var ignored error
+
+	config, err := newListConfig(options)
+	if err != nil {
+		return response, err
+	}
+
+	config.skipErrors = true
+	directory = Abs(directory)
+
+	err = walkList(directory, recursive, config, func(path string, info os.FileInfo) error {
+		response = append(response, &listpath{
+			Path: path,
+			Stat: info,
+		})
+
+		return nil
+	})
+
+	if err != nil {
+		return make([]*listpath, 0), err
+	}
+
+	if config.options.sorted() {
+		keys := make([]sortKey, len(response))
+		for i, item := range response {
+			keys[i] = newSortKey(directory, item.Path, item.Stat)
+		}
+
+		sortListing(keys, func(i, j int) {
+			response[i], response[j] = response[j], response[i]
+		}, &config.options)
+	}
+
+	return response, nil
+}
+
+// walkList calls fn for each path of the directory that is not ignored,
+// as the directory is walked.
+func walkList(directory string, recursive bool, config *listConfig, fn func(path string, info os.FileInfo) error) error {
+	rules := config.rules.walk(directory)
 
 	// Walk recursive lists
 	if recursive {
-		_ = filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
+		err := walk(directory, config.options.WalkOptions, func(path string, info os.FileInfo, err error) error {
+			if err != nil && !config.skipErrors {
 				return err
 			}
 
-			ignored = isIgnoredPath(path, ignore...)
-			if ignored != nil {
-				return ignored
+			// A directory that cannot be read is still listed, but not walked.
+			if info == nil {
+				return nil
 			}
 
-			response = append(response, &listpath{
-				Path: path,
-				Stat: info,
-			})
+			ignored := isIgnoredPath(directory, path, config.ignore)
+			if !ignored {
+				if ignored, err = rules.ignored(path, info.IsDir()); err != nil {
+					return err
+				}
+			}
 
-			return nil
-		})
-	} else {
-		paths, matchErr := filepath.Glob(filepath.Clean(filepath.Join(directory, "/*")))
-
-		if matchErr == nil {
-			for _, path := range paths {
-				ignored = isIgnoredPath(path, ignore...)
-				if ignored == nil {
-					stat, _ := os.Stat(path)
-					response = append(response, &listpath{
-						Path: path,
-						Stat: stat,
-					})
+			if ignored {
+				// Prune ignored directories instead of aborting the walk.
+				if info.IsDir() {
+					return filepath.SkipDir
 				}
+
+				return nil
 			}
-		} else {
-			return make([]*listpath, 0), matchErr
+
+			depth := listDepth(directory, path)
+			descend := !info.IsDir() || config.options.descend(path, info, depth)
+
+			if config.options.selected(path, info, depth) {
+				if err = fn(path, info); err != nil {
+					return err
+				}
+			}
+
+			if !descend {
+				return filepath.SkipDir
+			}
+
+			return nil
+		})
+
+		if err == StopList {
+			return nil
 		}
+
+		return err
 	}
 
-	return response, nil
-}
+	// Symlinks are described by their targets (like os.Stat), so symlinked
+	// directories are listed as directories, as they always have been.
+	w := &walker{opts: WalkOptions{FollowSymlinks: true}}
+
+	entries, err := w.readDir(directory)
+	if err != nil {
+		return err
+	}
 
-func isIgnoredPath(path string, ignore ...string) error {
-	if len(ignore) > 0 {
-		for _, pattern := range ignore {
-			matched, matchErr := filepath.Match(pattern, path)
+	// Load the ignore files of the directory itself.
+	if _, err = rules.ignored(directory, true); err != nil {
+		return err
+	}
 
-			if matchErr != nil {
-				return matchErr
+	for _, entry := range entries {
+		if entry.err != nil {
+			return entry.err
+		}
+
+		path, stat := entry.path, entry.info
+
+		ignored := isIgnoredPath(directory, path, config.ignore)
+		if !ignored {
+			if ignored, err = rules.ignored(path, stat.IsDir()); err != nil {
+				return err
 			}
+		}
+
+		if ignored || !config.options.selected(path, stat, 1) {
+			continue
+		}
 
-			if matched {
-				return errors.New("Ignored")
+		if err = fn(path, stat); err != nil && err != filepath.SkipDir {
+			if err == StopList {
+				return nil
 			}
+
+			return err
 		}
 	}
-
 	return nil
 }
 
+// listDepth determines the depth of a path within the listed directory.
+func listDepth(directory string, path string) int {
+	rel, err := filepath.Rel(directory, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+
+	return strings.Count(rel, string(os.PathSeparator)) + 1
+}
+
+// isIgnoredPath determines whether a path matches the ignore patterns.
+// Each pattern is matched against the absolute path, the path relative
+// to the root directory and the base name of the path.
+func isIgnoredPath(root string, path string, ignore globSet) bool {
+	if len(ignore) == 0 {
+		return false
+	}
+
+	names := []string{path}
+	if rel, err := filepath.Rel(root, path); err == nil && rel != "." {
+		names = append(names, rel, filepath.Base(path))
+	}
+
+	return ignore.matchAny(names...)
+}
+
 // Generate a list of path names for the given directory.
 // Optionally provide a list of ignored paths, using
-// [glob](https://en.wikipedia.org/wiki/Glob_%28programming%29) syntax.
+// [glob](https://en.wikipedia.org/wiki/Glob_%28programming%29) syntax
+// (including `**` and brace alternatives, see GlobMatch).
+// Patterns are matched against the absolute path, the path relative
+// to the directory (i.e. `logs/*.log`) and the base name (i.e. `*.log`).
+// The contents of ignored directories are not listed. Recursive listings
+// skip the directories that cannot be read (i.e. for lack of permission)
+// and keep walking, so errors are only returned for non-recursive
+// listings (i.e. when the directory does not exist). Use ListWith for
+// more options, or ListEntries or ListFunc to receive walk errors.
+// Symlinks are not followed by recursive listings (see WalkOptions), while
+// non-recursive listings describe them by their targets, so symlinked
+// directories are listed as directories.
 func List(directory string, recursive bool, ignore ...string) ([]string, error) {
-	response, err := list(directory, recursive, ignore...)
+	return ListWith(directory, recursive, ListOptions{Ignore: ignore})
+}
+
+// ListWith lists the directory like List, configured by ListOptions to
+// ignore paths (using glob patterns and/or gitignore rules), filter entries
+// by depth, type, visibility, size and modification time, sort them, and
+// control how the directory is walked.
+func ListWith(directory string, recursive bool, options ListOptions) ([]string, error) {
+	response, err := list(directory, recursive, options)
 	if err != nil {
 		return make([]string, 0), err
 	}
@@ -314,10 +561,41 @@ func List(directory string, recursive bool, ignore ...string) ([]string, error)
 	return paths, nil
 }
 
+// StopList can be returned by the callback of ListFunc to stop listing.
+// It is not returned as an error by any function.
+var StopList = errors.New("stop listing")
+
+// ListFunc calls fn for each path of the directory as the directory is
+// walked, instead of collecting all paths in memory, so very large trees
+// can be processed in constant memory. Optionally provide ListOptions
+// (except for sorting, which does not apply). Return StopList from fn to stop listing early, or
+// filepath.SkipDir to skip the contents of a directory (see filepath.Walk).
+// Any other error stops listing and is returned, as are walk errors.
+func ListFunc(directory string, recursive bool, fn func(path string, info os.FileInfo) error, options ...ListOptions) error {
+	opts := ListOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	config, err := newListConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	return walkList(Abs(directory), recursive, config, fn)
+}
+
 // ListDirectories provides absolute paths of directories only, ignoring files.
+// It accepts the same ignore patterns as List.
 func ListDirectories(directory string, recursive bool, ignore ...string) ([]string, error) {
+	return ListDirectoriesWith(directory, recursive, ListOptions{Ignore: ignore})
+}
+
+// ListDirectoriesWith lists directories only, like ListDirectories,
+// configured by ListOptions (see ListWith).
+func ListDirectoriesWith(directory string, recursive bool, options ListOptions) ([]string, error) {
 	paths := make([]string, 0)
-	response, err := list(directory, recursive, ignore...)
+	response, err := list(directory, recursive, options)
 	if err != nil {
 		return paths, err
 	}
@@ -336,9 +614,16 @@ func ListDirectories(directory string, recursive bool, ignore ...string) ([]stri
 }
 
 // ListFiles provides absolute paths of files only, ignoring directories.
+// It accepts the same ignore patterns as List.
 func ListFiles(directory string, recursive bool, ignore ...string) ([]string, error) {
+	return ListFilesWith(directory, recursive, ListOptions{Ignore: ignore})
+}
+
+// ListFilesWith lists files only, like ListFiles,
+// configured by ListOptions (see ListWith).
+func ListFilesWith(directory string, recursive bool, options ListOptions) ([]string, error) {
 	paths := make([]string, 0)
-	response, err := list(directory, recursive, ignore...)
+	response, err := list(directory, recursive, options)
 	if err != nil {
 		return paths, err
 	}
@@ -357,11 +642,19 @@ func ListFiles(directory string, recursive bool, ignore ...string) ([]string, er
 }
 
 // ByteSize returns the number of bytes (size) of a file/directory.
-func ByteSize(path string) (int64, error) {
+// Symlinks are not followed (their own size is counted), unless
+// WalkOptions with FollowSymlinks are provided. Files reachable
+// through several symlinks are then counted once per link.
+func ByteSize(path string, options ...WalkOptions) (int64, error) {
 	path = Abs(path)
 
+	opts := WalkOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
 	var size int64
-	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+	err := walk(path, opts, func(_ string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -393,7 +686,10 @@ const TB float64 = 1024 * GB
 // PB represents the size of a petabyte.
 const PB float64 = 1024 * TB
 
-// Size returns a "pretty" version of the size, such as "3.12MB"
+// EB represents the size of an exabyte.
+const EB float64 = 1024 * PB
+
+// Size returns a "pretty" version of the size, such as "3.12MB".
 func Size(path string, sigfig ...int) (string, error) {
 	size, err := ByteSize(path)
 	if err != nil {
@@ -403,31 +699,15 @@ func Size(path string, sigfig ...int) (string, error) {
 	return FormatSize(size, sigfig...), nil
 }
 
-// FormatSize returns a nicely formatted representation of a number of bytes,
-// such as `3.14MB`
-func FormatSize(bytesize int64, sigfig ...int) string {
-	size := float64(bytesize)
-	var sigfigs int
-	if len(sigfig) == 0 {
-		sigfigs = 2
-	} else {
-		sigfigs = sigfig[0]
+// SizeWith returns a "pretty" version of the size like Size, formatted
+// with FormatSizeWith.
+func SizeWith(path string, precision int, options SizeOptions) (string, error) {
+	size, err := ByteSize(path)
+	if err != nil {
+		return "", err
 	}
 
-	switch {
-	case size >= PB:
-		return strconv.FormatFloat(math.Round(((size*100)/PB))/100, 'f', sigfigs, 64) + "PB"
-	case size >= TB:
-		return strconv.FormatFloat(math.Round(((size*100)/TB))/100, 'f', sigfigs, 64) + "TB"
-	case size >= GB:
-		return strconv.FormatFloat(math.Round(((size*100)/GB))/100, 'f', sigfigs, 64) + "GB"
-	case size >= MB:
-		return strconv.FormatFloat(math.Round(((size*100)/MB))/100, 'f', sigfigs, 64) + "MB"
-	case size >= KB:
-		return strconv.FormatFloat(math.Round(((size*100)/KB))/100, 'f', sigfigs, 64) + "KB"
-	default:
-		return strconv.FormatInt(bytesize, 10) + "B"
-	}
+	return FormatSizeWith(size, precision, options), nil
 }
 
 // Symlink creates a symbolic link. This just runs `os.Symlink()`.
@@ -479,24 +759,61 @@ func Move(source string, dest string, ignoreErrors ...bool) error {
 	})
 }
 
-// Copy a file/directory
+// Copy a file/directory. Symlinks are skipped.
+// Optionally specify `true` to ignore errors.
 func Copy(source string, dest string, ignoreErrors ...bool) error {
-	ignore := false
+	options := CopyOptions{}
 	if len(ignoreErrors) > 0 {
-		ignore = ignoreErrors[0]
+		options.IgnoreErrors = ignoreErrors[0]
 	}
 
-	return filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+	return CopyWith(source, dest, options)
+}
+
+// CopyOptions configures CopyWith.
+type CopyOptions struct {
+	// IgnoreErrors continues copying when a file cannot be copied.
+	IgnoreErrors bool
+
+	// Ignore skips the files and directories matching gitignore
+	// rules (relative to the source).
+	Ignore *IgnoreRules
+
+	// WalkOptions determine whether symlinks are followed, copying their
+	// targets (symlinks are skipped otherwise), and whether the source
+	// is walked concurrently.
+	WalkOptions
+}
+
+// CopyWith copies a file/directory like Copy, configured by CopyOptions.
+func CopyWith(source string, dest string, options CopyOptions) error {
+	ignore := options.IgnoreErrors
+	ignores := options.Ignore.walk(source)
+
+	return walk(source, options.WalkOptions, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
+		ignored, err := ignores.ignored(path, info.IsDir())
+		if err != nil {
+			return err
+		}
+
+		if ignored {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
 		stub := strings.Replace(path, source, "", 1)
 		target := filepath.Join(dest, stub)
 
 		if info.IsDir() {
 			Touch(target)
-		} else if !IsSymlink(path) {
+		} else if info.Mode()&os.ModeSymlink == 0 {
 			input, err := ioutil.ReadFile(path)
 			if err != nil && !ignore {
 				return err
@@ -512,8 +829,10 @@ func Copy(source string, dest string, ignoreErrors ...bool) error {
 	})
 }
 
-// Unzip a file
-func Unzip(src string, dest string) error {
+// Unzip a file into the destination directory.
+// An optional UnzipOptions argument controls how the file modes
+// stored in the archive are applied (see ModePolicy).
+func Unzip(src string, dest string, options ...UnzipOptions) (err error) {
 	src = Abs(src)
 	if !Exists(src) {
 		return errors.New(src + " does not exist")
@@ -521,130 +840,94 @@ func Unzip(src string, dest string) error {
 
 	dest = Abs(dest)
 
-	r, err := zip.OpenReader(src)
-	if err != nil {
-		return err
+	opts := UnzipOptions{}
+	if len(options) > 0 {
+		opts = options[0]
 	}
-	defer func() {
-		if err := r.Close(); err != nil {
-			panic(err)
-		}
-	}()
-
-	os.MkdirAll(dest, 0755)
-
-	// Closure to address file descriptors issue with all the deferred .Close() methods
-	extractAndWriteFile := func(f *zip.File) error {
-		rc, err := f.Open()
-		if err != nil {
-			return err
-		}
-		defer func() {
-			if err := rc.Close(); err != nil {
-				panic(err)
-			}
-		}()
-
-		path := filepath.Join(dest, f.Name)
-
-		// Check for ZipSlip (Directory traversal)
-		if !strings.HasPrefix(path, filepath.Clean(dest)+string(os.PathSeparator)) {
-			return fmt.Errorf("illegal file path: %s", path)
-		}
 
-		if f.FileInfo().IsDir() {
-			os.MkdirAll(path, f.Mode())
-		} else {
-			os.MkdirAll(filepath.Dir(path), f.Mode())
-			f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-			if err != nil {
-				return err
-			}
-			defer func() {
-				if err := f.Close(); err != nil {
-					panic(err)
-				}
-			}()
-
-			_, err = io.Copy(f, rc)
-			if err != nil {
-				return err
-			}
-		}
-		return nil
-	}
-
-	for _, f := range r.File {
-		err := extractAndWriteFile(f)
-		if err != nil {
-			return err
-		}
+	r, err := openZip(src)
+	if err != nil {
+		return err
 	}
+	defer closeWithError(r, &err)
 
-	return nil
+	return unzip(r.Reader, dest, &opts)
 }
 
 // Zip a file or directory. Does not follow symlinks.
+// Optionally provide the destination path. By default, the archive is
+// created beside the source, named after it (i.e. `./path/to/src.zip`).
+// Use ZipWith to configure the archive.
 func Zip(src string, target ...string) error {
-	dest := strings.Replace(filepath.Base(src), filepath.Ext(src), "", 1) + ".zip"
+	dest := defaultArchivePath(Abs(src), ".zip")
 	if len(target) > 0 {
 		dest = target[0]
 	}
 
+	return ZipWith(src, dest, ZipOptions{})
+}
+
+// ZipWith zips a file or directory into the destination path,
+// configured by the ZipOptions.
+//
+// For example:
+// `fsutil.ZipWith("./src", "./dist/src.zip", fsutil.ZipOptions{IncludeRoot: true})`
+func ZipWith(src string, dest string, opts ZipOptions) (err error) {
+	src = Abs(src)
 	dest = Abs(dest)
 
-	// buf := new(bytes.Buffer)
-	newZipFile, err := os.Create(dest)
+	if opts.VolumeSize > 0 {
+		return zipVolumes(src, dest, &opts)
+	}
+
+	file, err := os.Create(dest)
 	if err != nil {
 		return err
 	}
-	defer newZipFile.Close()
+	defer closeWithError(file, &err)
 
-	writer := zip.NewWriter(newZipFile)
-	defer writer.Close()
-
-	filepath.Walk(src, func(path string, info fs.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+	return zipTo(file, src, &opts, dest)
+}
 
-		if !info.IsDir() && !IsSymlink(path) {
-			input, err := ioutil.ReadFile(path)
-			if err != nil {
-				return err
-			}
+// zipVolumes creates a split archive, by writing a single archive
+// to a temporary file before splitting it into volumes.
+func zipVolumes(src string, dest string, opts *ZipOptions) error {
+	if opts.VolumeSize < MinVolumeSize {
+		return fmt.Errorf("volume size must be at least %d bytes", MinVolumeSize)
+	}
 
-			localpath := strings.Replace(path, src, "", 1)
-			if strings.HasPrefix(localpath, "/") {
-				localpath = strings.Replace(localpath, "/", "", 1)
-			} else if strings.HasPrefix(localpath, "\\") {
-				localpath = strings.Replace(localpath, "\\", "", 1)
-			}
+	if err := removeVolumes(dest); err != nil {
+		return err
+	}
 
-			err = addToZipArchive(writer, localpath, input)
-			if err != nil {
-				return err
-			}
-		}
+	file, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+"-*")
+	if err != nil {
+		return err
+	}
 
-		return nil
-	})
+	err = zipTo(file, src, opts, dest, file.Name())
+	closeWithError(file, &err)
 
-	return nil
-}
+	if err == nil {
+		err = splitZip(file.Name(), dest, opts.VolumeSize)
+	}
 
-func addToZipArchive(archive *zip.Writer, filename string, content []byte) error {
-	f, err := archive.Create(filename)
 	if err != nil {
-		return err
+		os.Remove(file.Name())
 	}
 
-	_, err = f.Write(content)
-	if err != nil {
-		return err
+	return err
+}
+
+// defaultArchivePath places an archive beside the source, named after it.
+// The extension of a file is replaced, while directories keep their full name.
+func defaultArchivePath(src string, ext string) string {
+	name := filepath.Base(src)
+	if IsFile(src) {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
 	}
 
-	return nil
+	return filepath.Join(filepath.Dir(src), name+ext)
 }
 
 // TODO List
@@ -1,6 +1,15 @@
 package fsutil
 
-import "os"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// openNoFollow prevents opening a file through a symlink.
+const openNoFollow = syscall.O_NOFOLLOW
 
 func isExecutable(filepath string) bool {
 	info, err := os.Stat(filepath)
@@ -10,3 +19,53 @@ func isExecutable(filepath string) bool {
 
 	return (info.Mode()&0111 != 0)
 }
+
+var (
+	umaskOnce  sync.Once
+	umaskValue os.FileMode
+)
+
+// umask returns the file mode creation mask of the process, which is
+// read once, when first needed. The mask can only be read by setting it,
+// so it is immediately restored, which briefly applies to files created
+// concurrently.
+func umask() os.FileMode {
+	umaskOnce.Do(func() {
+		mask := syscall.Umask(0)
+		syscall.Umask(mask)
+		umaskValue = os.FileMode(mask)
+	})
+
+	return umaskValue
+}
+
+// fileID returns the device and inode numbers of a file, along with
+// its number of hard links. ok is false if they are unavailable.
+func fileID(info os.FileInfo) (dev uint64, ino uint64, nlink uint64, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, 0, false
+	}
+
+	return uint64(stat.Dev), uint64(stat.Ino), uint64(stat.Nlink), true
+}
+
+// isHidden determines whether a path is hidden, by convention
+// when its name starts with a dot.
+func isHidden(path string, info os.FileInfo) bool {
+	return strings.HasPrefix(filepath.Base(path), ".")
+}
+
+// openBeneath opens (or creates) the file at rel beneath root. The syscall
+// package does not provide openat on macOS, so the parent directories are
+// resolved by path: replacing one of them with a symlink after it was
+// checked is not detected.
+func openBeneath(root string, rel string, flag int, perm os.FileMode) (*os.File, error) {
+	return os.OpenFile(filepath.Join(root, rel), flag|openNoFollow, perm)
+}
+
+// mkdirBeneath creates the directory at rel beneath root, resolving
+// the parent directories by path (see openBeneath).
+func mkdirBeneath(root string, rel string, perm os.FileMode) error {
+	return os.Mkdir(filepath.Join(root, rel), perm)
+}
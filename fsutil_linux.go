@@ -1,6 +1,16 @@
 package fsutil
 
-import "os"
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// openNoFollow prevents opening a file through a symlink.
+const openNoFollow = syscall.O_NOFOLLOW
 
 func isExecutable(filepath string) bool {
 	info, err := os.Stat(filepath)
@@ -10,3 +20,119 @@ func isExecutable(filepath string) bool {
 
 	return (info.Mode()&0111 != 0)
 }
+
+var (
+	umaskOnce  sync.Once
+	umaskValue os.FileMode
+)
+
+// umask returns the file mode creation mask of the process,
+// which is read once, when first needed.
+func umask() os.FileMode {
+	umaskOnce.Do(func() {
+		umaskValue = readUmask()
+	})
+
+	return umaskValue
+}
+
+// readUmask reads the mask from /proc/self/status (Linux 4.7+). Older
+// kernels only allow reading the mask by setting it, so it is immediately
+// restored, which briefly applies to files created concurrently.
+func readUmask() os.FileMode {
+	if data, err := os.ReadFile("/proc/self/status"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if value := strings.TrimPrefix(line, "Umask:"); value != line {
+				if mask, err := strconv.ParseUint(strings.TrimSpace(value), 8, 32); err == nil {
+					return os.FileMode(mask)
+				}
+			}
+		}
+	}
+
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+	return os.FileMode(mask)
+}
+
+// fileID returns the device and inode numbers of a file, along with
+// its number of hard links. ok is false if they are unavailable.
+func fileID(info os.FileInfo) (dev uint64, ino uint64, nlink uint64, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, 0, false
+	}
+
+	return uint64(stat.Dev), uint64(stat.Ino), uint64(stat.Nlink), true
+}
+
+// isHidden determines whether a path is hidden, by convention
+// when its name starts with a dot.
+func isHidden(path string, info os.FileInfo) bool {
+	return strings.HasPrefix(filepath.Base(path), ".")
+}
+
+// openParent opens the parent directory of rel beneath root, one path
+// component at a time, relative to the file descriptor of the previous
+// directory. No component may be a symlink, so the path cannot be
+// redirected outside of root, even if a directory is replaced by a
+// symlink after it was checked.
+func openParent(root string, rel string) (int, string, error) {
+	const flags = syscall.O_RDONLY | syscall.O_DIRECTORY | syscall.O_CLOEXEC
+
+	dir, err := syscall.Open(root, flags, 0)
+	if err != nil {
+		return -1, "", &os.PathError{Op: "open", Path: root, Err: err}
+	}
+
+	parts := strings.Split(rel, string(os.PathSeparator))
+	current := root
+
+	for _, part := range parts[:len(parts)-1] {
+		current = filepath.Join(current, part)
+
+		next, err := syscall.Openat(dir, part, flags|syscall.O_NOFOLLOW, 0)
+		syscall.Close(dir)
+		if err != nil {
+			return -1, "", &os.PathError{Op: "open", Path: current, Err: err}
+		}
+
+		dir = next
+	}
+
+	return dir, parts[len(parts)-1], nil
+}
+
+// openBeneath opens (or creates) the file at rel beneath root,
+// without following symlinks in any component of the path.
+func openBeneath(root string, rel string, flag int, perm os.FileMode) (*os.File, error) {
+	dir, name, err := openParent(root, rel)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.Close(dir)
+
+	path := filepath.Join(root, rel)
+	fd, err := syscall.Openat(dir, name, flag|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, uint32(perm.Perm()))
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	return os.NewFile(uintptr(fd), path), nil
+}
+
+// mkdirBeneath creates the directory at rel beneath root,
+// without following symlinks in any component of the path.
+func mkdirBeneath(root string, rel string, perm os.FileMode) error {
+	dir, name, err := openParent(root, rel)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(dir)
+
+	if err = syscall.Mkdirat(dir, name, uint32(perm.Perm())); err != nil {
+		return &os.PathError{Op: "mkdir", Path: filepath.Join(root, rel), Err: err}
+	}
+
+	return nil
+}
@@ -5,8 +5,10 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 var testDir string = "./.data/a/b"
@@ -519,6 +521,322 @@ func TestList(t *testing.T) {
 	clear()
 }
 
+func TestListUnreadable(t *testing.T) {
+	clear()
+
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("directory permissions are not enforced")
+	}
+
+	abs := Abs(testDir)
+	WriteTextFile(filepath.Join(abs, "a", "test.txt"), "test")
+	WriteTextFile(filepath.Join(abs, "locked", "secret.txt"), "secret")
+	WriteTextFile(filepath.Join(abs, "z", "test.txt"), "test")
+
+	locked := filepath.Join(abs, "locked")
+	os.Chmod(locked, 0)
+	defer os.Chmod(locked, 0755)
+
+	// The unreadable directory is listed, but not walked.
+	list, err := ListWith(abs, true, ListOptions{Sort: SortName})
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+
+	expected := []string{
+		abs,
+		filepath.Join(abs, "a"),
+		filepath.Join(abs, "a", "test.txt"),
+		locked,
+		filepath.Join(abs, "z"),
+		filepath.Join(abs, "z", "test.txt"),
+	}
+
+	if strings.Join(list, "\n") != strings.Join(expected, "\n") {
+		t.Logf("Expected %v, received %v", expected, list)
+		t.Fail()
+	}
+
+	if _, err = ListEntries(abs, true); err == nil {
+		t.Log("Expected ListEntries to report the unreadable directory.")
+		t.Fail()
+	}
+
+	os.Chmod(locked, 0755)
+	clear()
+}
+
+func TestListNonRecursive(t *testing.T) {
+	clear()
+
+	// Glob metacharacters in the directory name must not be expanded.
+	abs := Abs(filepath.Join(testDir, "we[i]rd"))
+	WriteTextFile(filepath.Join(abs, "test.txt"), "test")
+	WriteTextFile(filepath.Join(abs, ".hidden"), "hidden")
+	Mkdirp(filepath.Join(abs, "sub"))
+
+	if runtime.GOOS != "windows" {
+		os.Symlink("dne", filepath.Join(abs, "dangling"))
+	}
+
+	files, err := ListFiles(abs, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := 2
+	if runtime.GOOS != "windows" {
+		expected++
+	}
+
+	if len(files) != expected {
+		t.Logf("Expected %d files, received %v", expected, files)
+		t.Fail()
+	}
+
+	list, err := ListWith(abs, false, ListOptions{SkipHidden: true, Types: []EntryType{EntryFile, EntryDir}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 2 || filepath.Base(list[0]) != "sub" || filepath.Base(list[1]) != "test.txt" {
+		t.Logf("Expected sub and test.txt, received %v", list)
+		t.Fail()
+	}
+
+	if _, err = List("./dne", false); !os.IsNotExist(err) {
+		t.Logf("Expected a not exist error, received %v", err)
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestListIgnore(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	WriteTextFile(filepath.Join(abs, "app.log"), "log")
+	WriteTextFile(filepath.Join(abs, "logs", "2021", "app.log"), "log")
+	WriteTextFile(filepath.Join(abs, "node_modules", "pkg", "index.js"), "js")
+	WriteTextFile(filepath.Join(abs, "src", "index.js"), "js")
+
+	// Base name patterns match nested files.
+	list, err := ListFiles(abs, true, "*.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 2 {
+		t.Logf("Expected 2 results, received %v", list)
+		t.Fail()
+	}
+
+	// Ignored directories are pruned, without aborting the walk.
+	list, err = List(abs, true, "node_modules", "logs/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		abs,
+		filepath.Join(abs, "app.log"),
+		filepath.Join(abs, "logs"),
+		filepath.Join(abs, "src"),
+		filepath.Join(abs, "src", "index.js"),
+	}
+
+	if strings.Join(list, "\n") != strings.Join(expected, "\n") {
+		t.Logf("Expected %v, received %v", expected, list)
+		t.Fail()
+	}
+
+	list, err = List(abs, false, "*.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 3 {
+		t.Logf("Expected 3 results, received %v", list)
+		t.Fail()
+	}
+
+	// Patterns and rules can be combined in ListOptions.
+	patterns := []string{"node_modules"}
+	list, err = ListWith(abs, true, ListOptions{Ignore: patterns, IgnoreRules: NewIgnoreRules("logs/")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 4 {
+		t.Logf("Expected 4 results, received %v", list)
+		t.Fail()
+	}
+
+	count := 0
+	err = ListFunc(abs, true, func(string, os.FileInfo) error {
+		count++
+		return nil
+	}, ListOptions{Ignore: patterns, IgnoreRules: NewIgnoreRules("logs/")})
+
+	if err != nil || count != 4 {
+		t.Logf("Expected 4 results, received %v (%v)", count, err)
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestListOptions(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	WriteTextFile(filepath.Join(abs, "a.txt"), "a")
+	WriteTextFile(filepath.Join(abs, "big.bin"), strings.Repeat("0", 1024))
+	WriteTextFile(filepath.Join(abs, ".hidden", "secret.txt"), "secret")
+	WriteTextFile(filepath.Join(abs, "one", "file.txt"), "file")
+	WriteTextFile(filepath.Join(abs, "one", "two", "three", "deep.txt"), "deep")
+
+	old := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(filepath.Join(abs, "a.txt"), old, old)
+
+	tests := []struct {
+		name     string
+		list     func(string, bool, ListOptions) ([]string, error)
+		options  ListOptions
+		expected int
+	}{
+		{"exact depth", ListFilesWith, ListOptions{MinDepth: 2, MaxDepth: 2}, 2},
+		{"max depth without hidden", ListDirectoriesWith, ListOptions{MaxDepth: 2, SkipHidden: true}, 3},
+		{"type and size", ListWith, ListOptions{Types: []EntryType{EntryFile}, MinSize: 100}, 1},
+		{"max size", ListFilesWith, ListOptions{MaxSize: 1, SkipHidden: true}, 1},
+		{"modified before", ListFilesWith, ListOptions{ModifiedBefore: time.Now().Add(-time.Hour)}, 1},
+		{"modified after", ListFilesWith, ListOptions{ModifiedAfter: time.Now().Add(-time.Hour)}, 4},
+	}
+
+	for _, test := range tests {
+		list, err := test.list(abs, true, test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(list) != test.expected {
+			t.Logf("%v: expected %v results, received %v", test.name, test.expected, list)
+			t.Fail()
+		}
+	}
+
+	list, err := ListWith(abs, false, ListOptions{SkipHidden: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 3 {
+		t.Logf("Expected 3 non-hidden results, received %v", list)
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestListSort(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	WriteTextFile(filepath.Join(abs, "file10.txt"), "ccc")
+	WriteTextFile(filepath.Join(abs, "file2.txt"), "a")
+	WriteTextFile(filepath.Join(abs, "File1.txt"), "bb")
+	WriteTextFile(filepath.Join(abs, "b.md"), "dddd")
+	WriteTextFile(filepath.Join(abs, "sub", "x.txt"), "x")
+
+	tests := []struct {
+		name      string
+		list      func(string, bool, ListOptions) ([]string, error)
+		recursive bool
+		options   ListOptions
+		expected  []string
+	}{
+		{"natural name", ListWith, false, ListOptions{Sort: SortName}, []string{"b.md", "File1.txt", "file2.txt", "file10.txt", "sub"}},
+		{"directories first", ListWith, false, ListOptions{DirsFirst: true}, []string{"sub", "b.md", "File1.txt", "file2.txt", "file10.txt"}},
+		{"descending name", ListWith, false, ListOptions{Sort: SortName, Descending: true}, []string{"sub", "file10.txt", "file2.txt", "File1.txt", "b.md"}},
+		{"size", ListFilesWith, false, ListOptions{Sort: SortSize}, []string{"file2.txt", "File1.txt", "file10.txt", "b.md"}},
+		{"descending size", ListFilesWith, true, ListOptions{Sort: SortSize, Descending: true}, []string{"b.md", "file10.txt", "File1.txt", "x.txt", "file2.txt"}},
+		{"extension", ListFilesWith, false, ListOptions{Sort: SortExtension}, []string{"b.md", "File1.txt", "file2.txt", "file10.txt"}},
+		{"recursive tree", ListWith, true, ListOptions{Sort: SortName, DirsFirst: true}, []string{"b", "sub", "x.txt", "b.md", "File1.txt", "file2.txt", "file10.txt"}},
+	}
+
+	for _, test := range tests {
+		list, err := test.list(abs, test.recursive, test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names := make([]string, len(list))
+		for i, path := range list {
+			names[i] = filepath.Base(path)
+		}
+
+		if strings.Join(names, ",") != strings.Join(test.expected, ",") {
+			t.Logf("%v: expected %v, received %v", test.name, test.expected, names)
+			t.Fail()
+		}
+	}
+
+	clear()
+}
+
+func TestListFunc(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	for _, name := range []string{"x", "y", "z"} {
+		WriteTextFile(filepath.Join(abs, name, "test.txt"), name)
+	}
+
+	paths := make([]string, 0)
+	err := ListFunc(abs, true, func(path string, info os.FileInfo) error {
+		if info.IsDir() && info.Name() == "y" {
+			return filepath.SkipDir
+		}
+
+		paths = append(paths, path)
+		if len(paths) == 4 {
+			return StopList
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		abs,
+		filepath.Join(abs, "x"),
+		filepath.Join(abs, "x", "test.txt"),
+		filepath.Join(abs, "z"),
+	}
+
+	if strings.Join(paths, "\n") != strings.Join(expected, "\n") {
+		t.Logf("Expected %v, received %v", expected, paths)
+		t.Fail()
+	}
+
+	// Walk errors are reported.
+	err = ListFunc("./dne", true, func(path string, info os.FileInfo) error {
+		return nil
+	})
+
+	if !os.IsNotExist(err) {
+		t.Logf("Expected a not exist error, received %v", err)
+		t.Fail()
+	}
+
+	clear()
+}
+
 func TestByteSize(t *testing.T) {
 	clear()
 	os.MkdirAll("./.data", os.ModePerm)
@@ -5,8 +5,14 @@ package fsutil
 import (
 	"debug/pe"
 	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
 )
 
+// openNoFollow is not supported on Windows.
+const openNoFollow = 0
+
 func isExecutable(filepath string) bool {
 	// Open the file
 	file, err := os.Open(Abs(filepath))
@@ -39,3 +45,42 @@ func isExecutable(filepath string) bool {
 	// If all checks pass, return true
 	return true
 }
+
+// umask is not supported on Windows, so nothing is masked.
+func umask() os.FileMode {
+	return 0
+}
+
+// fileID is not supported on Windows.
+func fileID(info os.FileInfo) (dev uint64, ino uint64, nlink uint64, ok bool) {
+	return 0, 0, 0, false
+}
+
+// isHidden determines whether a path is hidden, either by its hidden
+// file attribute or by convention when its name starts with a dot.
+func isHidden(path string, info os.FileInfo) bool {
+	if strings.HasPrefix(filepath.Base(path), ".") {
+		return true
+	}
+
+	if info != nil {
+		if data, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+			return data.FileAttributes&syscall.FILE_ATTRIBUTE_HIDDEN != 0
+		}
+	}
+
+	return false
+}
+
+// openBeneath opens (or creates) the file at rel beneath root. Windows has
+// no openat, so the parent directories are resolved by path: replacing one
+// of them with a symlink after it was checked is not detected.
+func openBeneath(root string, rel string, flag int, perm os.FileMode) (*os.File, error) {
+	return os.OpenFile(filepath.Join(root, rel), flag|openNoFollow, perm)
+}
+
+// mkdirBeneath creates the directory at rel beneath root, resolving
+// the parent directories by path (see openBeneath).
+func mkdirBeneath(root string, rel string, perm os.FileMode) error {
+	return os.Mkdir(filepath.Join(root, rel), perm)
+}
@@ -0,0 +1,251 @@
+package fsutil
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// globPattern is a compiled glob pattern, split into path segments.
+type globPattern struct {
+	segments []string
+	negate   bool
+}
+
+// globSet is a list of glob patterns, where the last matching pattern
+// determines whether a path matches.
+type globSet []globPattern
+
+// compileGlobs compiles glob patterns, expanding brace alternatives.
+// Patterns starting with `!` exclude the paths matched by earlier patterns.
+func compileGlobs(patterns ...string) (globSet, error) {
+	set := make(globSet, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		negate := strings.HasPrefix(pattern, "!")
+		if negate {
+			pattern = pattern[1:]
+		}
+
+		for _, alternative := range expandBraces(filepath.ToSlash(pattern)) {
+			segments := strings.Split(alternative, "/")
+			for i, segment := range segments {
+				segments[i] = globSegment(segment)
+
+				// Detect malformed patterns up front.
+				if _, err := path.Match(segments[i], ""); err != nil {
+					return nil, err
+				}
+			}
+
+			set = append(set, globPattern{segments: segments, negate: negate})
+		}
+	}
+
+	return set, nil
+}
+
+// globSegment converts a segment to the syntax of path.Match, which
+// negates character classes with `^` rather than `!`.
+func globSegment(segment string) string {
+	return strings.ReplaceAll(segment, "[!", "[^")
+}
+
+// expandBraces expands the brace alternatives of a pattern, i.e.
+// `*.{go,mod}` becomes `*.go` and `*.mod`. Braces may be nested.
+// Unbalanced braces are matched literally.
+func expandBraces(pattern string) []string {
+	start, depth := -1, 0
+	commas := make([]int, 0)
+
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		case '}':
+			if depth == 0 {
+				continue
+			}
+
+			depth--
+			if depth > 0 {
+				continue
+			}
+
+			prefix, suffix := pattern[:start], pattern[i+1:]
+			bounds := append(append([]int{start}, commas...), i)
+			expanded := make([]string, 0)
+
+			for j := 0; j < len(bounds)-1; j++ {
+				alternative := pattern[bounds[j]+1 : bounds[j+1]]
+				expanded = append(expanded, expandBraces(prefix+alternative+suffix)...)
+			}
+
+			return expanded
+		}
+	}
+
+	return []string{pattern}
+}
+
+// match determines whether a slash-separated path matches the pattern.
+func (p globPattern) match(name string) bool {
+	return matchSegments(p.segments, strings.Split(name, "/"))
+}
+
+// matchSegments matches the segments of a slash-separated path against
+// the segments of a pattern, where a `**` segment matches zero or more
+// path segments.
+func matchSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if matched, err := path.Match(pattern[0], name[0]); err != nil || !matched {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// matchAny determines whether any of the names match the set.
+func (s globSet) matchAny(names ...string) bool {
+	matched := false
+
+	for _, pattern := range s {
+		if matched != pattern.negate {
+			continue
+		}
+
+		for _, name := range names {
+			if pattern.match(filepath.ToSlash(name)) {
+				matched = !pattern.negate
+				break
+			}
+		}
+	}
+
+	return matched
+}
+
+// canMatchWithin determines whether any pattern of the set may match
+// a path inside the (slash-separated) directory.
+func (s globSet) canMatchWithin(dir string) bool {
+	parts := strings.Split(dir, "/")
+
+	for _, pattern := range s {
+		if !pattern.negate && matchPrefix(pattern.segments, parts) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchPrefix determines whether the leading segments of a pattern
+// match all of the directory segments, leaving segments to match the
+// contents of the directory.
+func matchPrefix(pattern []string, dir []string) bool {
+	for _, part := range dir {
+		if len(pattern) == 0 {
+			return false
+		}
+
+		if pattern[0] == "**" {
+			return true
+		}
+
+		if matched, err := path.Match(pattern[0], part); err != nil || !matched {
+			return false
+		}
+
+		pattern = pattern[1:]
+	}
+
+	return len(pattern) > 0
+}
+
+// GlobMatch determines whether a slash-separated path matches a glob
+// pattern. In addition to the syntax of path.Match, patterns support
+// `**` (any number of directories), brace alternatives (`{a,b}`),
+// character classes negated with `!` (`[!a-z]`), and a leading `!`
+// to negate the entire pattern.
+func GlobMatch(pattern string, name string) (bool, error) {
+	set, err := compileGlobs(pattern)
+	if err != nil {
+		return false, err
+	}
+
+	if strings.HasPrefix(pattern, "!") {
+		set = append(globSet{{segments: []string{"**"}}}, set...)
+	}
+
+	return set.matchAny(name), nil
+}
+
+// Glob provides the absolute paths of the files and directories within
+// root whose path relative to root matches any of the glob patterns (see
+// GlobMatch), i.e. `src/**/*.{go,mod}`. Patterns starting with `!`
+// exclude the paths matched by earlier patterns. Only directories that
+// can contain matches are walked.
+func Glob(root string, patterns ...string) ([]string, error) {
+	root = Abs(root)
+	matches := make([]string, 0)
+
+	set, err := compileGlobs(patterns...)
+	if err != nil {
+		return matches, err
+	}
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		if rel == "." {
+			return nil
+		}
+
+		rel = filepath.ToSlash(rel)
+		if set.matchAny(rel) {
+			matches = append(matches, path)
+		}
+
+		if d.IsDir() && !set.canMatchWithin(rel) {
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+
+	return matches, err
+}
@@ -0,0 +1,90 @@
+package fsutil
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		matched bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "src/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "src/pkg/main.go", true},
+		{"src/**/*.{go,mod}", "src/go.mod", true},
+		{"src/**/*.{go,mod}", "src/a/b/main.go", true},
+		{"src/**/*.{go,mod}", "src/a/b/main.sum", false},
+		{"src/**", "src/a/b", true},
+		{"{a,b/{c,d}}/*.txt", "b/d/file.txt", true},
+		{"{a,b/{c,d}}/*.txt", "b/e/file.txt", false},
+		{"file[0-9].txt", "file1.txt", true},
+		{"file[!0-9].txt", "file1.txt", false},
+		{"file[!0-9].txt", "filea.txt", true},
+		{"!*.go", "main.go", false},
+		{"!*.go", "main.js", true},
+		{"\\{a,b\\}", "{a,b}", true},
+	}
+
+	for _, test := range tests {
+		matched, err := GlobMatch(test.pattern, test.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if matched != test.matched {
+			t.Logf("Expected %v to match %v: %v", test.pattern, test.name, test.matched)
+			t.Fail()
+		}
+	}
+
+	if _, err := GlobMatch("[a-", "a"); err == nil {
+		t.Log("Expected an error for a malformed pattern")
+		t.Fail()
+	}
+}
+
+func TestGlob(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	WriteTextFile(filepath.Join(abs, "go.mod"), "module")
+	WriteTextFile(filepath.Join(abs, "src", "go.mod"), "module")
+	WriteTextFile(filepath.Join(abs, "src", "main.go"), "package main")
+	WriteTextFile(filepath.Join(abs, "src", "pkg", "util.go"), "package pkg")
+	WriteTextFile(filepath.Join(abs, "src", "pkg", "util_test.go"), "package pkg")
+	WriteTextFile(filepath.Join(abs, "src", "pkg", "README.md"), "readme")
+
+	matches, err := Glob(abs, "src/**/*.{go,mod}", "!**/*_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		filepath.Join(abs, "src", "go.mod"),
+		filepath.Join(abs, "src", "main.go"),
+		filepath.Join(abs, "src", "pkg", "util.go"),
+	}
+
+	if strings.Join(matches, "\n") != strings.Join(expected, "\n") {
+		t.Logf("Expected %v, received %v", expected, matches)
+		t.Fail()
+	}
+
+	// List ignore patterns support the same syntax.
+	list, err := ListFiles(abs, true, "src/**/*.{go,mod}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 2 {
+		t.Logf("Expected 2 results, received %v", list)
+		t.Fail()
+	}
+
+	clear()
+}
@@ -0,0 +1,251 @@
+package fsutil
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GzipOptions configures the behavior of Gzip and Gunzip.
+type GzipOptions struct {
+	// Dest is the output file path. Gzip defaults to the source path
+	// with a `.gz` extension, and Gunzip to the source path without its
+	// `.gz` extension.
+	Dest string
+
+	// RestoreName makes Gunzip default to the original name stored in
+	// the gzip header (in the directory of the source) instead. The name
+	// is chosen by whoever created the file, so only restore the names
+	// of trusted files.
+	RestoreName bool
+
+	// Force replaces an existing output file. Otherwise, the output
+	// is not written when the file exists (os.ErrExist).
+	Force bool
+
+	// Level is the compression level used by Gzip (see compress/gzip).
+	// Zero uses gzip.DefaultCompression, so gzip.NoCompression (which is
+	// also zero) cannot be selected: use gzip.BestSpeed or
+	// gzip.HuffmanOnly for fast compression instead.
+	Level int
+
+	// RemoveOriginal removes the source file once the output
+	// has been written successfully (like the gzip command).
+	RemoveOriginal bool
+}
+
+// Gzip compresses a single file, storing its name and modification time
+// in the gzip header (names that are not Latin-1 are omitted, since the
+// header cannot store them). The output is streamed, so files of any size
+// can be compressed, and has the permissions of the source file (like the
+// gzip command). Returns the absolute path of the compressed file.
+func Gzip(path string, options ...GzipOptions) (string, error) {
+	opts := GzipOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	path = Abs(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if info.IsDir() {
+		return "", errors.New(path + " is a directory")
+	}
+
+	dest := path + ".gz"
+	if len(opts.Dest) > 0 {
+		dest = Abs(opts.Dest)
+	}
+
+	if err = checkOverwrite(dest, opts.Force); err != nil {
+		return "", err
+	}
+
+	level := opts.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	err = writeAtomic(dest, info.Mode().Perm(), func(w io.Writer) error {
+		gz, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return err
+		}
+
+		if latin1(info.Name()) {
+			gz.Name = info.Name()
+		}
+		gz.ModTime = info.ModTime()
+
+		if err = copyFileTo(gz, path); err != nil {
+			return err
+		}
+
+		return gz.Close()
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if opts.RemoveOriginal {
+		return dest, os.Remove(path)
+	}
+
+	return dest, nil
+}
+
+// Gunzip decompresses a single gzip file (i.e. `app.log.gz` to `app.log`),
+// restoring the modification time stored in the gzip header. The output
+// has the permissions of the compressed file (like the gzip command). The
+// trailing checksum is verified, and the output is only written when it
+// matches (gzip.ErrChecksum otherwise). Returns the absolute path of the
+// decompressed file.
+func Gunzip(path string, options ...GzipOptions) (string, error) {
+	opts := GzipOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	path = Abs(path)
+	dest, err := gunzip(path, &opts)
+	if err != nil {
+		return "", err
+	}
+
+	if opts.RemoveOriginal {
+		return dest, os.Remove(path)
+	}
+
+	return dest, nil
+}
+
+func gunzip(path string, opts *GzipOptions) (dest string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer closeWithError(file, &err)
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return "", err
+	}
+
+	name := ""
+	if opts.RestoreName {
+		name = gz.Name
+	}
+
+	dest = gunzipPath(path, name)
+	if dest == path {
+		dest = path + ".out"
+	}
+
+	if len(opts.Dest) > 0 {
+		dest = Abs(opts.Dest)
+	}
+
+	if err = checkOverwrite(dest, opts.Force); err != nil {
+		return "", err
+	}
+
+	err = writeAtomic(dest, info.Mode().Perm(), func(w io.Writer) error {
+		// The checksum is verified once the end of the stream is reached.
+		_, err := io.Copy(w, gz)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if !gz.ModTime.IsZero() {
+		if err = os.Chtimes(dest, gz.ModTime, gz.ModTime); err != nil {
+			return "", err
+		}
+	}
+
+	return dest, nil
+}
+
+// latin1 determines whether a name can be stored in a gzip header,
+// which only supports Latin-1 (ISO 8859-1) strings without NUL bytes.
+func latin1(name string) bool {
+	for _, r := range name {
+		if r == 0 || r > 0xff {
+			return false
+		}
+	}
+
+	return true
+}
+
+// gunzipPath determines the default output path of a gzip file,
+// preferring the original name stored in the header (if any). Only the
+// base name is used, so the header cannot direct output to another directory.
+func gunzipPath(path string, name string) string {
+	name = filepath.Base(filepath.FromSlash(name))
+	if len(name) > 0 && name != "." && name != string(os.PathSeparator) && name != ".." {
+		return filepath.Join(filepath.Dir(path), name)
+	}
+
+	for _, ext := range []string{".gz", ".gzip"} {
+		if strings.HasSuffix(strings.ToLower(path), ext) {
+			return path[:len(path)-len(ext)]
+		}
+	}
+
+	return path + ".out"
+}
+
+// checkOverwrite prevents replacing an existing file unless forced.
+func checkOverwrite(dest string, force bool) error {
+	if force {
+		return nil
+	}
+
+	if _, err := os.Lstat(dest); err == nil {
+		return &os.PathError{Op: "write", Path: dest, Err: os.ErrExist}
+	}
+
+	return nil
+}
+
+// writeAtomic writes a file through a temporary file in the same
+// directory, which only replaces dest once write succeeds. The file
+// is given the permissions of perm.
+func writeAtomic(dest string, perm os.FileMode, write func(io.Writer) error) (err error) {
+	Mkdirp(filepath.Dir(dest))
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+"-*")
+	if err != nil {
+		return err
+	}
+
+	err = write(tmp)
+	closeWithError(tmp, &err)
+
+	if err == nil {
+		err = os.Chmod(tmp.Name(), perm)
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), dest)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+
+	return err
+}
@@ -0,0 +1,225 @@
+package fsutil
+
+import (
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestGzip(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	path := filepath.Join(abs, "app.log")
+	content := "test content"
+
+	if err := WriteTextFile(path, content); err != nil {
+		t.Fatal(err)
+	}
+
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	os.Chtimes(path, modified, modified)
+	os.Chmod(path, 0600)
+
+	archive, err := Gzip(path, GzipOptions{RemoveOriginal: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The permissions of the source are kept.
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info.Mode().Perm() != 0600 {
+			t.Logf("Expected the permissions of the source file, received %v", info.Mode())
+			t.Fail()
+		}
+	}
+
+	if archive != path+".gz" || Exists(path) {
+		t.Logf("Expected %v to replace the original file, received %v", path+".gz", archive)
+		t.Fail()
+	}
+
+	// The output is named after the compressed file by default.
+	renamed := filepath.Join(abs, "renamed.gz")
+	os.Rename(archive, renamed)
+
+	out, err := Gunzip(renamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out != filepath.Join(abs, "renamed") {
+		t.Logf("Expected %v, received %v", filepath.Join(abs, "renamed"), out)
+		t.Fail()
+	}
+
+	// The original name can be restored from the gzip header.
+	out, err = Gunzip(renamed, GzipOptions{RestoreName: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out != path {
+		t.Logf("Expected the original name %v, received %v", path, out)
+		t.Fail()
+	}
+
+	data, _ := ReadTextFile(out)
+	if data != content {
+		t.Log("Decompressed file contents do not match")
+		t.Fail()
+	}
+
+	if mod, _ := LastModified(out); !mod.Equal(modified) {
+		t.Logf("Expected modification time %v, received %v", modified, mod)
+		t.Fail()
+	}
+
+	if !Exists(renamed) {
+		t.Log("The compressed file should be kept by default.")
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestGunzipChecksum(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	path := filepath.Join(abs, "app.log")
+	WriteTextFile(path, "test content")
+
+	archive, err := Gzip(path, GzipOptions{Dest: filepath.Join(abs, "app.gz"), Level: gzip.BestSpeed})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the trailing checksum.
+	data, _ := os.ReadFile(archive)
+	data[len(data)-8] ^= 0xff
+	os.WriteFile(archive, data, 0644)
+
+	out := filepath.Join(abs, "out.log")
+	if _, err = Gunzip(archive, GzipOptions{Dest: out}); !errors.Is(err, gzip.ErrChecksum) {
+		t.Logf("Expected gzip.ErrChecksum, received %v", err)
+		t.Fail()
+	}
+
+	if Exists(out) {
+		t.Log("Output should not be written when the checksum does not match.")
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestGunzipOverwrite(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	important := filepath.Join(abs, "important.conf")
+	WriteTextFile(important, "important")
+
+	// A download whose header names an existing file.
+	source := filepath.Join(abs, "source")
+	WriteTextFile(source, "malicious")
+	archive, err := Gzip(source, GzipOptions{Dest: filepath.Join(abs, "download.gz")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(archive)
+	data[3] |= 0x08 // FNAME
+	patched := append([]byte{}, data[:10]...)
+	patched = append(patched, "important.conf\x00"...)
+	patched = append(patched, data[10+len("source")+1:]...)
+	os.WriteFile(archive, patched, 0644)
+
+	if _, err = Gunzip(archive, GzipOptions{RestoreName: true}); !errors.Is(err, os.ErrExist) {
+		t.Logf("Expected os.ErrExist, received %v", err)
+		t.Fail()
+	}
+
+	if content, _ := ReadTextFile(important); content != "important" {
+		t.Log("An existing file should not be replaced.")
+		t.Fail()
+	}
+
+	if _, err = Gzip(source); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = Gzip(source); !errors.Is(err, os.ErrExist) {
+		t.Logf("Expected os.ErrExist, received %v", err)
+		t.Fail()
+	}
+
+	out, err := Gunzip(archive, GzipOptions{RestoreName: true, Force: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if content, _ := ReadTextFile(out); out != important || content != "malicious" {
+		t.Logf("Expected %v to be replaced when forced, received %v", important, out)
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestGzipName(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+
+	// Names that are not Latin-1 cannot be stored in the header.
+	tests := map[string]string{
+		"日本.log":   "",
+		"café.log": "café.log",
+	}
+
+	for name, header := range tests {
+		source := filepath.Join(abs, name)
+		WriteTextFile(source, "content")
+
+		archive, err := Gzip(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		file, err := os.Open(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			t.Fatal(err)
+		}
+
+		if r.Name != header {
+			t.Logf("Expected the header name %q, received %q", header, r.Name)
+			t.Fail()
+		}
+		file.Close()
+
+		os.Remove(source)
+		if out, err := Gunzip(archive); err != nil || out != source {
+			t.Logf("Expected %v to be restored, received %v (%v)", source, out, err)
+			t.Fail()
+		}
+	}
+
+	clear()
+}
@@ -0,0 +1,245 @@
+package fsutil
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreRules matches paths using the semantics of
+// [gitignore](https://git-scm.com/docs/gitignore) files:
+//
+//   - `#` starts a comment and `!` negates a pattern (re-including a path)
+//   - patterns containing a slash (i.e. `/build` or `docs/*.md`) are
+//     anchored to the directory of the rule, others match at any level
+//   - a trailing slash (i.e. `logs/`) only matches directories
+//   - `**` matches any number of directories (i.e. `src/**/*.test.js`)
+//
+// As with git, the last matching pattern wins and a path cannot be
+// re-included when one of its parent directories is ignored.
+type IgnoreRules struct {
+	rules []ignoreRule
+	files []string
+}
+
+type ignoreRule struct {
+	// base is the slash-separated directory of the rule,
+	// relative to the root ("" for the root itself).
+	base     string
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// NewIgnoreRules creates rules from gitignore patterns (the lines of
+// an ignore file), which are relative to the root of a walk.
+func NewIgnoreRules(patterns ...string) *IgnoreRules {
+	rules := &IgnoreRules{}
+	rules.Add(patterns...)
+	return rules
+}
+
+// ReadIgnoreFile reads the rules of an ignore file, such as a
+// `.gitignore` or `.dockerignore` file.
+func ReadIgnoreFile(path string) (*IgnoreRules, error) {
+	rules := &IgnoreRules{}
+
+	patterns, err := readIgnoreFile(Abs(path))
+	if err != nil {
+		return nil, err
+	}
+
+	rules.Add(patterns...)
+	return rules, nil
+}
+
+func readIgnoreFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	patterns := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+
+	return patterns, scanner.Err()
+}
+
+// Add appends patterns relative to the root of a walk. Later
+// patterns take precedence over earlier ones.
+func (r *IgnoreRules) Add(patterns ...string) {
+	r.add("", patterns...)
+}
+
+func (r *IgnoreRules) add(base string, patterns ...string) {
+	for _, pattern := range patterns {
+		if rule, ok := parseIgnoreRule(base, pattern); ok {
+			r.rules = append(r.rules, rule)
+		}
+	}
+}
+
+// WithFiles loads additional rules from the ignore files with the
+// given names (i.e. `.gitignore`) in every directory of a walk, as git
+// does. Their patterns are relative to the directory containing them,
+// and take precedence over the rules of parent directories.
+func (r *IgnoreRules) WithFiles(names ...string) *IgnoreRules {
+	r.files = append(r.files, names...)
+	return r
+}
+
+// Match determines whether a path, relative to the root of the rules,
+// is ignored. Parent directories of the path are matched as well.
+func (r *IgnoreRules) Match(name string, isDir bool) bool {
+	name = strings.Trim(path.Clean("/"+filepath.ToSlash(name)), "/")
+	if len(name) == 0 {
+		return false
+	}
+
+	parts := strings.Split(name, "/")
+	for i := 1; i < len(parts); i++ {
+		if r.match(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+
+	return r.match(name, isDir)
+}
+
+func (r *IgnoreRules) match(name string, isDir bool) bool {
+	ignored := false
+
+	for _, rule := range r.rules {
+		if ignored != rule.negate || (rule.dirOnly && !isDir) {
+			continue
+		}
+
+		rel := name
+		if len(rule.base) > 0 {
+			if !strings.HasPrefix(name, rule.base+"/") {
+				continue
+			}
+
+			rel = name[len(rule.base)+1:]
+		}
+
+		if matchSegments(rule.segments, strings.Split(rel, "/")) {
+			ignored = !rule.negate
+		}
+	}
+
+	return ignored
+}
+
+// parseIgnoreRule parses a single line of an ignore file.
+func parseIgnoreRule(base string, line string) (ignoreRule, bool) {
+	rule := ignoreRule{base: base}
+
+	line = strings.TrimSuffix(line, "\r")
+	if len(line) == 0 || line[0] == '#' {
+		return rule, false
+	}
+
+	// Trailing spaces are ignored, unless they are escaped.
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+
+	if line[0] == '!' {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if len(line) == 0 {
+		return rule, false
+	}
+
+	if !anchored {
+		rule.segments = append(rule.segments, "**")
+	}
+
+	for _, segment := range strings.Split(line, "/") {
+		rule.segments = append(rule.segments, globSegment(segment))
+	}
+
+	// A trailing `**` matches everything inside, but not the directory itself.
+	if rule.segments[len(rule.segments)-1] == "**" {
+		rule.segments = append(rule.segments, "*")
+	}
+
+	return rule, true
+}
+
+// ignoreWalk applies ignore rules during a walk of root, loading the
+// rules of nested ignore files as directories are entered.
+type ignoreWalk struct {
+	root  string
+	rules IgnoreRules
+}
+
+// walk prepares the rules for a walk of root. The rules themselves
+// are not modified by the walk. Nil rules ignore nothing.
+func (r *IgnoreRules) walk(root string) *ignoreWalk {
+	if r == nil {
+		return nil
+	}
+
+	w := &ignoreWalk{root: root}
+	w.rules.rules = append(w.rules.rules, r.rules...)
+	w.rules.files = r.files
+
+	return w
+}
+
+// ignored determines whether a path of the walk is ignored.
+// The ignore files of directories that are not ignored are loaded,
+// so the rules apply to their contents.
+func (w *ignoreWalk) ignored(path string, isDir bool) (bool, error) {
+	if w == nil {
+		return false, nil
+	}
+
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil {
+		return false, err
+	}
+
+	rel = filepath.ToSlash(rel)
+	if rel == "." {
+		rel = ""
+	} else if w.rules.match(rel, isDir) {
+		return true, nil
+	}
+
+	if isDir {
+		for _, name := range w.rules.files {
+			patterns, err := readIgnoreFile(filepath.Join(path, name))
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+
+				return false, err
+			}
+
+			w.rules.add(rel, patterns...)
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,125 @@
+package fsutil
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	rules := NewIgnoreRules(
+		"# comment",
+		"*.log",
+		"!important.log",
+		"/build",
+		"tmp/",
+		"docs/**/*.md",
+		"!docs/keep/*.md",
+		"**/cache/*.bin",
+		"[!a]*.bak",
+	)
+
+	tests := []struct {
+		path    string
+		dir     bool
+		ignored bool
+	}{
+		{"app.log", false, true},
+		{"logs/nested/app.log", false, true},
+		{"important.log", false, false},
+		{"build", true, true},
+		{"build/output.js", false, true},
+		{"src/build", true, false},
+		{"tmp", true, true},
+		{"tmp", false, false},
+		{"src/tmp/file.txt", false, true},
+		{"docs/readme.md", false, true},
+		{"docs/a/b/readme.md", false, true},
+		{"docs/keep/readme.md", false, false},
+		{"readme.md", false, false},
+		{"cache/data.bin", false, true},
+		{"a/b/cache/data.bin", false, true},
+		{"backup.bak", false, true},
+		{"archive.bak", false, false},
+		{"# comment", false, false},
+	}
+
+	for _, test := range tests {
+		if rules.Match(test.path, test.dir) != test.ignored {
+			t.Logf("Expected %v (dir: %v) to be ignored: %v", test.path, test.dir, test.ignored)
+			t.Fail()
+		}
+	}
+}
+
+func TestIgnoreFiles(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	src := filepath.Join(abs, "src")
+
+	WriteTextFile(filepath.Join(src, ".gitignore"), "*.log\nnode_modules/\n")
+	WriteTextFile(filepath.Join(src, "app.log"), "log")
+	WriteTextFile(filepath.Join(src, "index.js"), "js")
+	WriteTextFile(filepath.Join(src, "node_modules", "pkg", "index.js"), "js")
+	WriteTextFile(filepath.Join(src, "lib", ".gitignore"), "!keep.log\n/generated.js\n")
+	WriteTextFile(filepath.Join(src, "lib", "keep.log"), "log")
+	WriteTextFile(filepath.Join(src, "lib", "other.log"), "log")
+	WriteTextFile(filepath.Join(src, "lib", "generated.js"), "js")
+	WriteTextFile(filepath.Join(src, "lib", "sub", "generated.js"), "js")
+
+	rules := NewIgnoreRules(".gitignore").WithFiles(".gitignore")
+	expected := []string{
+		filepath.Join(src, "index.js"),
+		filepath.Join(src, "lib", "keep.log"),
+		filepath.Join(src, "lib", "sub", "generated.js"),
+	}
+
+	list, err := ListFilesWith(src, true, ListOptions{IgnoreRules: rules})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != len(expected) {
+		t.Fatalf("Expected %v, received %v", expected, list)
+	}
+
+	for i := range expected {
+		if list[i] != expected[i] {
+			t.Logf("Expected %v, received %v", expected[i], list[i])
+			t.Fail()
+		}
+	}
+
+	// The rules are not modified by a walk.
+	if rules.Match("app.log", false) {
+		t.Log("Expected the loaded ignore files not to be retained after the walk")
+		t.Fail()
+	}
+
+	out := filepath.Join(abs, "out")
+	if err = CopyWith(src, out, CopyOptions{Ignore: rules}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !Exists(filepath.Join(out, "lib", "keep.log")) || Exists(filepath.Join(out, "node_modules")) || Exists(filepath.Join(out, "app.log")) {
+		t.Log("Expected the copy to respect the ignore files")
+		t.Fail()
+	}
+
+	archive := filepath.Join(abs, "src.zip")
+	if err = ZipWith(src, archive, ZipOptions{Ignore: rules}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ArchiveList(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != len(expected) {
+		t.Logf("Expected %v entries, received %v", len(expected), entries)
+		t.Fail()
+	}
+
+	clear()
+}
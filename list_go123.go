@@ -0,0 +1,28 @@
+//go:build go1.23
+
+package fsutil
+
+import (
+	"iter"
+	"os"
+)
+
+// ListSeq provides an iterator over the paths of the directory, which
+// are yielded as the directory is walked (see ListFunc). Optionally
+// provide ListOptions, like ListFunc. Walk errors are yielded with an empty
+// path, after which iteration ends. Breaking out of the loop stops the walk.
+func ListSeq(directory string, recursive bool, options ...ListOptions) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		err := ListFunc(directory, recursive, func(path string, _ os.FileInfo) error {
+			if !yield(path, nil) {
+				return StopList
+			}
+
+			return nil
+		}, options...)
+
+		if err != nil {
+			yield("", err)
+		}
+	}
+}
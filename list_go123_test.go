@@ -0,0 +1,46 @@
+//go:build go1.23
+
+package fsutil
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestListSeq(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		WriteTextFile(filepath.Join(abs, name), name)
+	}
+
+	count := 0
+	for path, err := range ListSeq(abs, false) {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if filepath.Base(path) != "a.txt" {
+			t.Logf("Expected a.txt, received %v", path)
+			t.Fail()
+		}
+
+		count++
+		break
+	}
+
+	if count != 1 {
+		t.Logf("Expected iteration to stop after 1 path, received %v", count)
+		t.Fail()
+	}
+
+	for _, err := range ListSeq("./dne", true) {
+		if err == nil {
+			t.Log("Expected an error for a missing directory")
+			t.Fail()
+		}
+	}
+
+	clear()
+}
@@ -0,0 +1,254 @@
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidSize is returned by ParseSize for sizes that cannot be parsed.
+var ErrInvalidSize = errors.New("invalid size")
+
+// UnitSystem determines the units of FormatSize and ParseSize.
+type UnitSystem int
+
+const (
+	// UnitsJEDEC uses multiples of 1024 labelled KB, MB, GB, etc.
+	// (the legacy JEDEC memory convention). This is the default.
+	UnitsJEDEC UnitSystem = iota
+
+	// UnitsIEC uses multiples of 1024 labelled KiB, MiB, GiB, etc.
+	UnitsIEC
+
+	// UnitsSI uses multiples of 1000 labelled kB, MB, GB, etc.
+	UnitsSI
+)
+
+// base is the multiple between the units of the system.
+func (u UnitSystem) base() float64 {
+	if u == UnitsSI {
+		return 1000
+	}
+
+	return 1024
+}
+
+// sizePrefixes are the unit prefixes, from kilo to exa.
+var sizePrefixes = []struct {
+	symbol  string
+	decimal string
+	binary  string
+}{
+	{"k", "kilo", "kibi"},
+	{"m", "mega", "mebi"},
+	{"g", "giga", "gibi"},
+	{"t", "tera", "tebi"},
+	{"p", "peta", "pebi"},
+	{"e", "exa", "exbi"},
+}
+
+// unitLabel is the label of the unit with the given exponent
+// (0 for bytes, 1 for kilobytes, etc).
+func (u UnitSystem) unitLabel(exponent int, long bool) string {
+	if exponent == 0 {
+		if long {
+			return "bytes"
+		}
+
+		return "B"
+	}
+
+	prefix := sizePrefixes[exponent-1]
+
+	switch {
+	case long && u == UnitsIEC:
+		return prefix.binary + "bytes"
+	case long:
+		return prefix.decimal + "bytes"
+	case u == UnitsIEC:
+		return strings.ToUpper(prefix.symbol) + "iB"
+	case u == UnitsSI && exponent == 1:
+		return "kB"
+	default:
+		return strings.ToUpper(prefix.symbol) + "B"
+	}
+}
+
+// SizeOptions determines how FormatSizeWith and SizeWith label sizes.
+type SizeOptions struct {
+	// Units is the unit system (UnitsJEDEC by default).
+	Units UnitSystem
+
+	// Space separates the number from the unit (i.e. `3.14 MB`).
+	Space bool
+
+	// LongNames labels sizes with unit names instead of symbols
+	// (i.e. `3.14 megabytes`), which are always separated by a space.
+	LongNames bool
+}
+
+// FormatSize returns a nicely formatted representation of a number of bytes,
+// such as `3.14MB`. Optionally specify the number of decimals (2 by default).
+// Use FormatSizeWith to change the units.
+func FormatSize(bytesize int64, sigfig ...int) string {
+	precision := 2
+	if len(sigfig) > 0 {
+		precision = sigfig[0]
+	}
+
+	return FormatSizeWith(bytesize, precision, SizeOptions{})
+}
+
+// FormatSizeWith formats a number of bytes like FormatSize, with the given
+// number of decimals (or -1 for as few as needed), using the units and labels
+// of the SizeOptions. Sizes of less than a kilobyte are always formatted as a
+// whole number of bytes.
+func FormatSizeWith(bytesize int64, precision int, opts SizeOptions) string {
+	base := opts.Units.base()
+	size := math.Abs(float64(bytesize))
+
+	exponent := 0
+	for exponent < len(sizePrefixes) && size >= base {
+		size /= base
+		exponent++
+	}
+
+	number := strconv.FormatInt(bytesize, 10)
+	if exponent > 0 {
+		number = strconv.FormatFloat(size, 'f', precision, 64)
+
+		// Rounding may reach the next unit (i.e. 1023.999KB as 1024.00KB).
+		if rounded, _ := strconv.ParseFloat(number, 64); rounded >= base && exponent < len(sizePrefixes) {
+			exponent++
+			number = strconv.FormatFloat(size/base, 'f', precision, 64)
+		}
+
+		if bytesize < 0 {
+			number = "-" + number
+		}
+	}
+
+	if opts.LongNames {
+		label := opts.Units.unitLabel(exponent, true)
+		if number == "1" {
+			label = strings.TrimSuffix(label, "s")
+		}
+
+		return number + " " + label
+	}
+
+	if opts.Space {
+		return number + " " + opts.Units.unitLabel(exponent, false)
+	}
+
+	return number + opts.Units.unitLabel(exponent, false)
+}
+
+// parseUnit determines the number of bytes of a (lower case) unit,
+// such as "mb", "mib" or "megabytes".
+func parseUnit(unit string, units UnitSystem) (float64, bool) {
+	switch unit {
+	case "", "b", "byte", "bytes":
+		return 1, true
+	}
+
+	// Decimal units are multiples of 1024 unless the unit
+	// system defines them as multiples of 1000.
+	base := 1024.0
+	if units != UnitsJEDEC {
+		base = 1000
+	}
+
+	for i, prefix := range sizePrefixes {
+		exponent := float64(i + 1)
+
+		switch unit {
+		case prefix.symbol, prefix.symbol + "b", prefix.decimal + "byte", prefix.decimal + "bytes":
+			return math.Pow(base, exponent), true
+		case prefix.symbol + "ib", prefix.binary + "byte", prefix.binary + "bytes":
+			return math.Pow(1024, exponent), true
+		}
+	}
+
+	return 0, false
+}
+
+// ParseSize parses a human readable size, such as `512MB`, `1.5 GiB`,
+// `2 kilobytes` or `100`, into a number of bytes. It is the inverse of
+// FormatSize, so units are case-insensitive multiples of 1024 (KB and KiB
+// are equivalent), unless the SI or IEC unit system is specified, in which
+// case KB, MB, etc. are multiples of 1000 (KiB, MiB, etc. are always
+// multiples of 1024). Spaces between the number and the unit are optional.
+// Fractional sizes are rounded to the nearest byte. Negative sizes (with a
+// leading `-`, as formatted by FormatSize) are allowed. Sizes of exactly
+// 2^63 bytes, such as `8.00EB` (FormatSize(math.MaxInt64)), are clamped to
+// math.MaxInt64 (or math.MinInt64 when negative).
+func ParseSize(size string, units ...UnitSystem) (int64, error) {
+	system := UnitsJEDEC
+	if len(units) > 0 {
+		system = units[0]
+	}
+
+	value := strings.TrimSpace(size)
+
+	sign := int64(1)
+	if strings.HasPrefix(value, "-") {
+		sign = -1
+		value = value[1:]
+	}
+
+	// Split the number from the unit.
+	i := 0
+	for i < len(value) && (isDigit(value[i]) || value[i] == '.') {
+		i++
+	}
+
+	number := value[:i]
+	unit := strings.ToLower(strings.TrimSpace(value[i:]))
+
+	multiplier, ok := parseUnit(unit, system)
+	if !ok || number == "" {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidSize, size)
+	}
+
+	// Whole numbers are parsed as integers, so large sizes are exact.
+	if !strings.Contains(number, ".") {
+		n, err := strconv.ParseUint(number, 10, 64)
+		overflow, bytes := bits.Mul64(n, uint64(multiplier))
+		if err != nil || overflow != 0 {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidSize, size)
+		}
+
+		return signedSize(size, sign, bytes)
+	}
+
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidSize, size)
+	}
+
+	bytes := math.Round(f * multiplier)
+	if bytes > 1<<63 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidSize, size)
+	}
+
+	return signedSize(size, sign, uint64(bytes))
+}
+
+// signedSize applies the sign to a parsed number of bytes,
+// clamping 2^63 bytes to the range of an int64.
+func signedSize(size string, sign int64, bytes uint64) (int64, error) {
+	switch {
+	case bytes < 1<<63:
+		return sign * int64(bytes), nil
+	case bytes > 1<<63:
+		return 0, fmt.Errorf("%w: %q", ErrInvalidSize, size)
+	case sign < 0:
+		return math.MinInt64, nil
+	default:
+		return math.MaxInt64, nil
+	}
+}
@@ -0,0 +1,109 @@
+package fsutil
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"0":                   0,
+		"100":                 100,
+		"100B":                100,
+		"512MB":               512 * 1024 * 1024,
+		"512 mb":              512 * 1024 * 1024,
+		"1.5 GiB":             1536 * 1024 * 1024,
+		"1KiB":                1024,
+		"  2 kb ":             2048,
+		"3.14MB":              3292529,
+		"0.5b":                1,
+		"1 PB":                1 << 50,
+		"8191PiB":             8191 << 50,
+		"9223372036854775807": math.MaxInt64,
+		"-2KB":                -2048,
+		"-1.5 KiB":            -1536,
+		"-0":                  0,
+		"8192PB":              math.MaxInt64,
+		"8.00EB":              math.MaxInt64,
+		"-8.00EB":             math.MinInt64,
+		"9223372036854775808": math.MaxInt64,
+	}
+
+	for input, expected := range tests {
+		size, err := ParseSize(input)
+		if err != nil || size != expected {
+			t.Logf("Expected %q to be %d, received %d (%v)", input, expected, size, err)
+			t.Fail()
+		}
+	}
+
+	for _, input := range []string{"", "MB", "-", "--1KB", "- 1KB", "+1KB", "1.2.3", "12 parsecs", "8193PB", "8.01EB", "18446744073709551616", "1e3"} {
+		if _, err := ParseSize(input); !errors.Is(err, ErrInvalidSize) {
+			t.Logf("Expected %q to be invalid, received %v", input, err)
+			t.Fail()
+		}
+	}
+
+	// Formatted sizes are parsed back within the rounding of FormatSize.
+	for _, size := range []int64{0, 1023, 1024, 123456, 987654321, 5 << 40, 3 << 50, -2048, -987654321, math.MaxInt64, math.MinInt64} {
+		parsed, err := ParseSize(FormatSize(size))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if math.Abs(float64(parsed-size)) > math.Abs(float64(size))/100 {
+			t.Logf("Expected %s to be about %d, received %d", FormatSize(size), size, parsed)
+			t.Fail()
+		}
+	}
+}
+
+func TestFormatSizeUnits(t *testing.T) {
+	tests := []struct {
+		size      int64
+		precision int
+		options   SizeOptions
+		expected  string
+	}{
+		{512, 2, SizeOptions{}, "512B"},
+		{3292529, 2, SizeOptions{}, "3.14MB"},
+		{3292529, 4, SizeOptions{}, "3.1400MB"},
+		{1234567, 4, SizeOptions{}, "1.1774MB"},
+		{1234567, -1, SizeOptions{Units: UnitsSI}, "1.234567MB"},
+		{1048575, 2, SizeOptions{}, "1.00MB"},
+		{999999, 2, SizeOptions{Units: UnitsSI}, "1.00MB"},
+		{1500, 1, SizeOptions{Units: UnitsSI}, "1.5kB"},
+		{1536, 1, SizeOptions{Units: UnitsIEC}, "1.5KiB"},
+		{1536, 1, SizeOptions{Units: UnitsIEC, Space: true}, "1.5 KiB"},
+		{1536, 1, SizeOptions{LongNames: true}, "1.5 kilobytes"},
+		{1 << 30, 0, SizeOptions{Units: UnitsIEC, LongNames: true}, "1 gibibyte"},
+		{1, 2, SizeOptions{LongNames: true}, "1 byte"},
+		{3 << 60, 0, SizeOptions{}, "3EB"},
+		{math.MaxInt64, 2, SizeOptions{Units: UnitsIEC}, "8.00EiB"},
+		{-2048, 0, SizeOptions{}, "-2KB"},
+	}
+
+	for _, test := range tests {
+		if formatted := FormatSizeWith(test.size, test.precision, test.options); formatted != test.expected {
+			t.Logf("Expected %d (%d, %+v) to be %s, received %s", test.size, test.precision, test.options, test.expected, formatted)
+			t.Fail()
+		}
+	}
+
+	if formatted := FormatSize(3292529, 1); formatted != "3.1MB" {
+		t.Logf("Expected 3.1MB, received %s", formatted)
+		t.Fail()
+	}
+
+	// Each unit system round-trips with ParseSize.
+	for _, units := range []UnitSystem{UnitsJEDEC, UnitsIEC, UnitsSI} {
+		for _, options := range []SizeOptions{{Units: units}, {Units: units, LongNames: true}} {
+			formatted := FormatSizeWith(5<<40, -1, options)
+			if parsed, err := ParseSize(formatted, units); err != nil || parsed != 5<<40 {
+				t.Logf("Expected %s to be %d, received %d (%v)", formatted, int64(5<<40), parsed, err)
+				t.Fail()
+			}
+		}
+	}
+}
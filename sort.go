@@ -0,0 +1,221 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SortBy determines the order of listed entries (see ListOptions).
+type SortBy int
+
+const (
+	// SortNone lists entries in walk order (lexical, depth-first).
+	SortNone SortBy = iota
+
+	// SortName orders entries by name, comparing numbers by their
+	// value (i.e. `file2` before `file10`) and ignoring case. Recursive
+	// listings remain grouped by directory, with each directory
+	// listed before its contents.
+	SortName
+
+	// SortSize orders entries by size.
+	SortSize
+
+	// SortModTime orders entries by modification time.
+	SortModTime
+
+	// SortExtension orders entries by file extension.
+	SortExtension
+)
+
+// sortKey holds the attributes of a listed entry used for sorting.
+type sortKey struct {
+	parts   []string
+	dir     bool
+	size    int64
+	modtime time.Time
+}
+
+func newSortKey(directory string, path string, info os.FileInfo) sortKey {
+	key := sortKey{}
+
+	if rel, err := filepath.Rel(directory, path); err == nil && rel != "." {
+		key.parts = strings.Split(rel, string(os.PathSeparator))
+	}
+
+	if info != nil {
+		key.dir = info.IsDir()
+		key.size = info.Size()
+		key.modtime = info.ModTime()
+	}
+
+	return key
+}
+
+// sorted determines whether the options require the listing to be sorted.
+func (opts *ListOptions) sorted() bool {
+	return opts.Sort != SortNone || opts.Descending || opts.DirsFirst
+}
+
+// listSorter sorts listed entries by their keys, calling swap to
+// reorder the entries along with the keys.
+type listSorter struct {
+	keys []sortKey
+	swap func(i, j int)
+	opts *ListOptions
+}
+
+func (s *listSorter) Len() int {
+	return len(s.keys)
+}
+
+func (s *listSorter) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.swap(i, j)
+}
+
+func (s *listSorter) Less(i, j int) bool {
+	a, b := &s.keys[i], &s.keys[j]
+
+	if s.opts.Sort == SortName || s.opts.Sort == SortNone {
+		return s.lessPath(a, b)
+	}
+
+	if s.opts.DirsFirst && a.dir != b.dir {
+		return a.dir
+	}
+
+	c := 0
+	switch s.opts.Sort {
+	case SortSize:
+		c = compareInt64(a.size, b.size)
+	case SortModTime:
+		c = compareInt64(a.modtime.UnixNano(), b.modtime.UnixNano())
+	case SortExtension:
+		c = strings.Compare(a.ext(), b.ext())
+	}
+
+	if c == 0 {
+		return s.lessPath(a, b)
+	}
+
+	if s.opts.Descending {
+		return c > 0
+	}
+
+	return c < 0
+}
+
+// lessPath compares the paths of two entries one directory at a time,
+// so directories are always listed before their contents.
+func (s *listSorter) lessPath(a, b *sortKey) bool {
+	for i := 0; i < len(a.parts) && i < len(b.parts); i++ {
+		if a.parts[i] == b.parts[i] {
+			continue
+		}
+
+		if s.opts.DirsFirst {
+			aDir := i < len(a.parts)-1 || a.dir
+			bDir := i < len(b.parts)-1 || b.dir
+			if aDir != bDir {
+				return aDir
+			}
+		}
+
+		c := naturalCompare(a.parts[i], b.parts[i])
+		if s.opts.Descending {
+			return c > 0
+		}
+
+		return c < 0
+	}
+
+	return len(a.parts) < len(b.parts)
+}
+
+func (k *sortKey) ext() string {
+	if len(k.parts) == 0 || k.dir {
+		return ""
+	}
+
+	return strings.ToLower(filepath.Ext(k.parts[len(k.parts)-1]))
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// naturalCompare compares strings in natural order, where runs of
+// digits are compared by their numeric value and letters ignore case.
+// Strings that only differ by case are ordered lexically.
+func naturalCompare(a, b string) int {
+	i, j := 0, 0
+
+	for i < len(a) && j < len(b) {
+		if isDigit(a[i]) && isDigit(b[j]) {
+			// Compare the numbers without their leading zeros,
+			// first by length, then digit by digit.
+			si, sj := i, j
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+
+			x := strings.TrimLeft(a[si:i], "0")
+			y := strings.TrimLeft(b[sj:j], "0")
+
+			if len(x) != len(y) {
+				return compareInt64(int64(len(x)), int64(len(y)))
+			}
+
+			if c := strings.Compare(x, y); c != 0 {
+				return c
+			}
+
+			continue
+		}
+
+		x, y := toLower(a[i]), toLower(b[j])
+		if x != y {
+			return compareInt64(int64(x), int64(y))
+		}
+
+		i++
+		j++
+	}
+
+	if c := compareInt64(int64(len(a)-i), int64(len(b)-j)); c != 0 {
+		return c
+	}
+
+	return strings.Compare(a, b)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func toLower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+
+	return c
+}
+
+// sortListing sorts listed entries by their keys (see listSorter).
+func sortListing(keys []sortKey, swap func(i, j int), opts *ListOptions) {
+	sort.Stable(&listSorter{keys: keys, swap: swap, opts: opts})
+}
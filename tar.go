@@ -0,0 +1,304 @@
+package fsutil
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsupportedCompression is returned when an archive cannot be
+// written with the requested compression (i.e. bzip2).
+var ErrUnsupportedCompression = errors.New("unsupported compression")
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+)
+
+// Tar a file or directory. The compression is determined by the
+// destination extension: `.tar.gz`/`.tgz` archives are gzipped and
+// `.tar` archives are uncompressed. bzip2 compression can only be read.
+// By default, a `.tar.gz` archive is created beside the source, named after it.
+//
+// Modes, owners, modification times, symlinks and hard links
+// are preserved. Symlinks are not followed, and sockets are skipped.
+func Tar(src string, target ...string) (err error) {
+	src = Abs(src)
+
+	dest := defaultArchivePath(src, ".tar.gz")
+	if len(target) > 0 {
+		dest = target[0]
+	}
+
+	dest = Abs(dest)
+
+	compress, err := tarCompression(dest)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer closeWithError(file, &err)
+
+	var w io.Writer = file
+	if compress {
+		gz := gzip.NewWriter(file)
+		defer closeWithError(gz, &err)
+		w = gz
+	}
+
+	writer := tar.NewWriter(w)
+	defer closeWithError(writer, &err)
+
+	return writeTar(writer, src, dest)
+}
+
+func tarCompression(dest string) (bool, error) {
+	name := strings.ToLower(dest)
+
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return true, nil
+	case strings.HasSuffix(name, ".tar.bz2"), strings.HasSuffix(name, ".tbz2"), strings.HasSuffix(name, ".tbz"):
+		return false, fmt.Errorf("%w: bzip2 archives cannot be written", ErrUnsupportedCompression)
+	default:
+		return false, nil
+	}
+}
+
+func writeTar(writer *tar.Writer, src string, dest string) error {
+	// Track hard links by device/inode, so each file is only stored once.
+	links := make(map[[2]uint64]string)
+
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Never add the archive to itself.
+		if path == dest {
+			return nil
+		}
+
+		name, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		if name == "." {
+			if info.IsDir() {
+				return nil
+			}
+			name = filepath.Base(src)
+		}
+
+		name = filepath.ToSlash(name)
+
+		// Sockets cannot be stored in a tar archive, so they are skipped (as GNU tar does).
+		if info.Mode()&os.ModeSocket != 0 {
+			return nil
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+
+		header.Name = name
+		if info.IsDir() {
+			header.Name += "/"
+		}
+
+		if info.Mode().IsRegular() {
+			if dev, ino, nlink, ok := fileID(info); ok && nlink > 1 {
+				key := [2]uint64{dev, ino}
+				if original, exists := links[key]; exists {
+					header.Typeflag = tar.TypeLink
+					header.Linkname = original
+					header.Size = 0
+				} else {
+					links[key] = name
+				}
+			}
+		}
+
+		if err = writer.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			return nil
+		}
+
+		return copyFileTo(writer, path)
+	})
+}
+
+func copyFileTo(w io.Writer, path string) (err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer closeWithError(file, &err)
+
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// Untar extracts a tar archive into the destination directory.
+// Plain, gzip and bzip2 compressed archives are detected automatically.
+// It applies the same safety checks as Unzip: entries may not traverse
+// outside of the destination (nor through symlinks), hard links and
+// symlinks must resolve inside the destination and the MaxSize option
+// limits the amount of data written. Modes (see ModePolicy), modification
+// times, symlinks and hard links are restored, as well as owners
+// (PreserveOwner) when enabled.
+func Untar(src string, dest string, options ...UnzipOptions) (err error) {
+	src = Abs(src)
+	if !Exists(src) {
+		return errors.New(src + " does not exist")
+	}
+
+	opts := UnzipOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer closeWithError(file, &err)
+
+	r, err := decompress(file)
+	if err != nil {
+		return err
+	}
+
+	return untar(tar.NewReader(r), Abs(dest), &opts)
+}
+
+// decompress detects gzip and bzip2 streams by their magic bytes.
+// Any other content is returned as-is.
+func decompress(r io.Reader) (io.Reader, error) {
+	buf := bufio.NewReader(r)
+
+	magic, err := buf.Peek(3)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(buf)
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return bzip2.NewReader(buf), nil
+	default:
+		return buf, nil
+	}
+}
+
+func untar(r *tar.Reader, dest string, opts *UnzipOptions) error {
+	if err := os.MkdirAll(dest, opts.dirMode()); err != nil {
+		return err
+	}
+
+	x := newExtractor(dest, opts)
+
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		// Archives created with `tar -C dir .` name their entries `./name`,
+		// starting with the destination itself, which already exists.
+		name := strings.TrimPrefix(header.Name, "./")
+		if header.Typeflag == tar.TypeDir && path.Clean(name) == "." {
+			continue
+		}
+
+		ok, err := opts.selected(name, header.FileInfo())
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			continue
+		}
+
+		if err = x.extractTarEntry(header, r); err != nil {
+			return err
+		}
+	}
+
+	return x.finish()
+}
+
+func (x *extractor) extractTarEntry(header *tar.Header, r io.Reader) error {
+	path, err := x.target(header.Name)
+	if err != nil {
+		return err
+	}
+
+	mode := header.FileInfo().Mode()
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		if err = x.mkdir(path, mode, header.ModTime); err != nil {
+			return err
+		}
+
+	case tar.TypeReg:
+		if err = x.writeFile(path, mode, r); err != nil {
+			return err
+		}
+
+		if err = os.Chtimes(path, header.ModTime, header.ModTime); err != nil {
+			return err
+		}
+
+	case tar.TypeSymlink:
+		if err = x.symlink(header.Linkname, path); err != nil {
+			return err
+		}
+
+	case tar.TypeLink:
+		original, err := x.target(header.Linkname)
+		if err != nil {
+			return err
+		}
+
+		if err = x.link(original, path); err != nil {
+			return err
+		}
+
+	default:
+		// Devices, FIFOs and other special files are not extracted.
+		return nil
+	}
+
+	return x.chown(path, header.Uid, header.Gid)
+}
@@ -0,0 +1,276 @@
+package fsutil
+
+import (
+	"archive/tar"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func writeTestTar(path string, headers ...*tar.Header) error {
+	Mkdirp(filepath.Dir(path))
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := tar.NewWriter(file)
+	for _, header := range headers {
+		if header.Mode == 0 {
+			header.Mode = 0644
+		}
+
+		if err = writer.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if header.Size > 0 {
+			if _, err = writer.Write(make([]byte, header.Size)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return writer.Close()
+}
+
+func TestTar(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	src := filepath.Join(abs, "src")
+	content := "test content"
+
+	err := WriteTextFile(filepath.Join(src, "test.txt"), content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = WriteTextFile(filepath.Join(src, "more", "test2.txt"), content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	os.Chtimes(filepath.Join(src, "test.txt"), modified, modified)
+	os.Chmod(filepath.Join(src, "test.txt"), 0640)
+
+	links := runtime.GOOS != "windows"
+	if links {
+		if err = os.Symlink("test.txt", filepath.Join(src, "link.txt")); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = os.Link(filepath.Join(src, "test.txt"), filepath.Join(src, "hard.txt")); err != nil {
+			t.Fatal(err)
+		}
+
+		// Sockets are skipped.
+		socket, err := net.Listen("unix", filepath.Join(src, "test.sock"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer socket.Close()
+	}
+
+	for _, name := range []string{"test.tar", "test.tar.gz", "test.tgz"} {
+		archive := filepath.Join(abs, name)
+		out := filepath.Join(abs, "out-"+name)
+
+		if err = Tar(src, archive); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = Untar(archive, out); err != nil {
+			t.Fatal(err)
+		}
+
+		data, _ := ReadTextFile(filepath.Join(out, "more", "test2.txt"))
+		if data != content {
+			t.Logf("%v: extracted file contents do not match", name)
+			t.Fail()
+		}
+
+		stat, err := os.Stat(filepath.Join(out, "test.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !stat.ModTime().Equal(modified) {
+			t.Logf("%v: expected modification time %v, received %v", name, modified, stat.ModTime())
+			t.Fail()
+		}
+
+		if links && stat.Mode().Perm() != 0640 {
+			t.Logf("%v: expected mode 0640, received %v", name, stat.Mode().Perm())
+			t.Fail()
+		}
+
+		if !links {
+			continue
+		}
+
+		target, err := os.Readlink(filepath.Join(out, "link.txt"))
+		if err != nil || target != "test.txt" {
+			t.Logf("%v: symlink was not restored (%v)", name, err)
+			t.Fail()
+		}
+
+		hard, err := os.Stat(filepath.Join(out, "hard.txt"))
+		if err != nil || !os.SameFile(stat, hard) {
+			t.Logf("%v: hard link was not restored (%v)", name, err)
+			t.Fail()
+		}
+
+		if Exists(filepath.Join(out, "test.sock")) {
+			t.Logf("%v: socket should not be archived", name)
+			t.Fail()
+		}
+	}
+
+	if err = Tar(src, filepath.Join(abs, "test.tar.bz2")); !errors.Is(err, ErrUnsupportedCompression) {
+		t.Logf("Expected ErrUnsupportedCompression, received %v", err)
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestUntarSafety(t *testing.T) {
+	clear()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require elevated privileges on Windows")
+	}
+
+	abs := Abs(testDir)
+
+	tests := map[string][]*tar.Header{
+		"traversal": {
+			{Name: "../evil.txt", Typeflag: tar.TypeReg, Size: 1},
+		},
+		"symlink": {
+			{Name: "evil", Typeflag: tar.TypeSymlink, Linkname: "../../outside"},
+		},
+		"absolute-symlink": {
+			{Name: "evil", Typeflag: tar.TypeSymlink, Linkname: "/etc"},
+		},
+		"chained-symlink": {
+			{Name: "up", Typeflag: tar.TypeSymlink, Linkname: "."},
+			{Name: "up/evil", Typeflag: tar.TypeSymlink, Linkname: ".."},
+		},
+		"hardlink": {
+			{Name: "evil", Typeflag: tar.TypeLink, Linkname: "../outside.txt"},
+		},
+	}
+
+	for name, headers := range tests {
+		archive := filepath.Join(abs, name+".tar")
+		if err := writeTestTar(archive, headers...); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := Untar(archive, filepath.Join(abs, "out", name)); err == nil {
+			t.Logf("%v: expected extraction to fail", name)
+			t.Fail()
+		}
+	}
+
+	// Size limit
+	archive := filepath.Join(abs, "large.tar")
+	err := writeTestTar(archive,
+		&tar.Header{Name: "a.bin", Typeflag: tar.TypeReg, Size: 600},
+		&tar.Header{Name: "b.bin", Typeflag: tar.TypeReg, Size: 600},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Untar(archive, filepath.Join(abs, "out", "large"), UnzipOptions{MaxSize: 1000})
+	if !errors.Is(err, ErrArchiveTooLarge) {
+		t.Logf("Expected ErrArchiveTooLarge, received %v", err)
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestUntarDotRoot(t *testing.T) {
+	clear()
+
+	// Entries of `tar -C dir -czf archive.tgz .`
+	abs := Abs(testDir)
+	archive := filepath.Join(abs, "dot.tar")
+	err := writeTestTar(archive,
+		&tar.Header{Name: "./", Typeflag: tar.TypeDir, Mode: 0755},
+		&tar.Header{Name: "./sub/", Typeflag: tar.TypeDir, Mode: 0755},
+		&tar.Header{Name: "./sub/a.txt", Typeflag: tar.TypeReg, Size: 4},
+		&tar.Header{Name: "./b.txt", Typeflag: tar.TypeReg, Size: 4},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(abs, "out")
+	if err = Extract(archive, out); err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsFile(filepath.Join(out, "sub", "a.txt")) || !IsFile(filepath.Join(out, "b.txt")) {
+		t.Log("Expected the ./ entries to be extracted.")
+		t.Fail()
+	}
+
+	selected := filepath.Join(abs, "selected")
+	if err = Untar(archive, selected, UnzipOptions{Include: []string{"sub/*.txt"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsFile(filepath.Join(selected, "sub", "a.txt")) || Exists(filepath.Join(selected, "b.txt")) {
+		t.Log("Expected Include patterns to match ./ entries.")
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestUntarBzip2(t *testing.T) {
+	clear()
+
+	bzip2, err := exec.LookPath("bzip2")
+	if err != nil {
+		t.Skip("bzip2 is not available")
+	}
+
+	abs := Abs(testDir)
+	archive := filepath.Join(abs, "test.tar")
+
+	err = writeTestTar(archive, &tar.Header{Name: "test.bin", Typeflag: tar.TypeReg, Size: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = exec.Command(bzip2, archive).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = Untar(archive+".bz2", filepath.Join(abs, "out"))
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+
+	if size, _ := ByteSize(filepath.Join(abs, "out", "test.bin")); size != 16 {
+		t.Logf("Expected a 16 byte file, received %v bytes", size)
+		t.Fail()
+	}
+
+	clear()
+}
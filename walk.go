@@ -0,0 +1,360 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// WalkOptions configures how directory trees are walked by ListWith (as
+// part of ListOptions), ByteSize, CopyWith and ZipWith (as part of
+// ZipOptions).
+type WalkOptions struct {
+	// FollowSymlinks walks symlinked directories and reports the targets
+	// of symlinks instead of the links themselves. Each directory is only
+	// walked once (identified by its device and inode), so symlink cycles
+	// do not cause infinite loops. Broken symlinks are still reported, as
+	// symlinks (see Entry.Broken). Non-recursive lists always report the
+	// targets of symlinks, since they do not walk any further.
+	FollowSymlinks bool
+
+	// Workers reads directories concurrently using the given number of
+	// goroutines, which is considerably faster for large trees. Zero or
+	// one walks sequentially. Callbacks are never called concurrently,
+	// and directories are always reported before their contents.
+	Workers int
+
+	// Ordered reports the entries of a concurrent walk in the same
+	// (lexical, depth-first) order as a sequential walk. Otherwise,
+	// entries are reported as soon as their directory has been read.
+	Ordered bool
+}
+
+// followedLink describes the target of a followed symlink.
+type followedLink struct {
+	os.FileInfo
+}
+
+// dirKey identifies a directory, by device and inode where supported
+// or by its resolved path otherwise.
+type dirKey struct {
+	dev  uint64
+	ino  uint64
+	path string
+}
+
+// walkEntry is an entry of a directory read during a walk.
+type walkEntry struct {
+	path string
+	info os.FileInfo
+	err  error
+}
+
+// walker walks a directory tree like filepath.Walk (in lexical order),
+// optionally following symlinks and reading directories concurrently.
+type walker struct {
+	opts    WalkOptions
+	visited map[dirKey]bool
+	pool    *walkPool
+}
+
+// walk walks root, calling fn for each file and directory (see
+// filepath.WalkFunc). Without WalkOptions, it behaves like filepath.Walk.
+func walk(root string, opts WalkOptions, fn filepath.WalkFunc) error {
+	w := &walker{opts: opts, visited: make(map[dirKey]bool)}
+
+	info, err := w.stat(root)
+	switch {
+	case err != nil:
+		err = fn(root, nil, err)
+	case opts.Workers > 1:
+		w.pool = newWalkPool(w, opts.Workers, !opts.Ordered)
+		defer w.pool.close()
+
+		if opts.Ordered {
+			err = w.walk(root, info, nil, fn)
+		} else {
+			err = w.walkUnordered(root, info, fn)
+		}
+	default:
+		err = w.walk(root, info, nil, fn)
+	}
+
+	if err == filepath.SkipDir {
+		return nil
+	}
+
+	return err
+}
+
+// stat describes a path, resolving symlinks when they are followed.
+// Broken symlinks are described by the link itself.
+func (w *walker) stat(path string) (os.FileInfo, error) {
+	info, err := os.Lstat(path)
+	if err != nil || !w.opts.FollowSymlinks || info.Mode()&os.ModeSymlink == 0 {
+		return info, err
+	}
+
+	if target, err := os.Stat(path); err == nil {
+		return followedLink{target}, nil
+	}
+
+	return info, nil
+}
+
+// readDir reads the sorted entries of a directory.
+func (w *walker) readDir(path string) ([]walkEntry, error) {
+	dirEntries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]walkEntry, len(dirEntries))
+	for i, dirEntry := range dirEntries {
+		entries[i].path = filepath.Join(path, dirEntry.Name())
+
+		if w.opts.FollowSymlinks && dirEntry.Type()&os.ModeSymlink != 0 {
+			entries[i].info, entries[i].err = w.stat(entries[i].path)
+		} else {
+			entries[i].info, entries[i].err = dirEntry.Info()
+		}
+	}
+
+	return entries, nil
+}
+
+// enter determines whether a directory is walked for the first time,
+// marking it as visited. Directories are only tracked when following
+// symlinks, since the tree cannot contain cycles otherwise.
+func (w *walker) enter(path string, info os.FileInfo) bool {
+	if !w.opts.FollowSymlinks {
+		return true
+	}
+
+	key := dirKey{}
+	if dev, ino, _, ok := fileID(info); ok {
+		key.dev, key.ino = dev, ino
+	} else if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		key.path = resolved
+	} else {
+		key.path = path
+	}
+
+	if w.visited[key] {
+		return false
+	}
+
+	w.visited[key] = true
+	return true
+}
+
+// walk walks a path depth-first. The contents of directories are read
+// by the walker itself, or taken from read when they were read ahead
+// by the workers of an ordered concurrent walk.
+func (w *walker) walk(path string, info os.FileInfo, read *dirRead, fn filepath.WalkFunc) error {
+	if !info.IsDir() {
+		return fn(path, info, nil)
+	}
+
+	// Directories that were already walked (through a symlink)
+	// are reported, but not walked again.
+	if !w.enter(path, info) {
+		return fn(path, info, nil)
+	}
+
+	var entries []walkEntry
+	var err error
+
+	if read != nil {
+		<-read.done
+		entries, err = read.entries, read.err
+	} else {
+		entries, err = w.readDir(path)
+	}
+
+	err1 := fn(path, info, err)
+
+	// If err != nil, the directory cannot be walked, so fn is given
+	// a chance to report the error (see filepath.Walk).
+	if err != nil || err1 != nil {
+		return err1
+	}
+
+	// Read the subdirectories ahead, in the order they are walked.
+	reads := make([]*dirRead, len(entries))
+	if w.pool != nil {
+		for i := len(entries) - 1; i >= 0; i-- {
+			if entries[i].err == nil && entries[i].info.IsDir() {
+				reads[i] = w.pool.schedule(entries[i].path, entries[i].info)
+			}
+		}
+	}
+
+	for i, entry := range entries {
+		if entry.err != nil {
+			if err := fn(entry.path, entry.info, entry.err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+
+			continue
+		}
+
+		err = w.walk(entry.path, entry.info, reads[i], fn)
+		if err != nil {
+			if !entry.info.IsDir() || err != filepath.SkipDir {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// walkUnordered walks root concurrently, reporting the contents of
+// each directory as soon as it has been read by a worker.
+func (w *walker) walkUnordered(root string, info os.FileInfo, fn filepath.WalkFunc) error {
+	if !info.IsDir() || !w.enter(root, info) {
+		return fn(root, info, nil)
+	}
+
+	w.pool.schedule(root, info)
+
+	for pending := 1; pending > 0; pending-- {
+		dir := <-w.pool.results
+
+		if err := fn(dir.path, dir.info, dir.err); err != nil || dir.err != nil {
+			if err != nil && err != filepath.SkipDir {
+				return err
+			}
+
+			continue
+		}
+
+		for _, entry := range dir.entries {
+			if entry.err != nil {
+				if err := fn(entry.path, entry.info, entry.err); err != nil && err != filepath.SkipDir {
+					return err
+				}
+
+				continue
+			}
+
+			// Directories are reported once they have been read.
+			if entry.info.IsDir() && w.enter(entry.path, entry.info) {
+				w.pool.schedule(entry.path, entry.info)
+				pending++
+				continue
+			}
+
+			if err := fn(entry.path, entry.info, nil); err != nil {
+				if err != filepath.SkipDir {
+					return err
+				}
+
+				// Skip the remaining files of the directory.
+				if !entry.info.IsDir() {
+					break
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// dirRead is a directory read by the workers of a concurrent walk.
+// done is closed once the directory has been read.
+type dirRead struct {
+	path    string
+	info    os.FileInfo
+	entries []walkEntry
+	err     error
+	done    chan struct{}
+}
+
+// walkPool reads directories concurrently. The most recently scheduled
+// directories are read first, which keeps reads close to the order in
+// which they are walked and limits the number of pending directories.
+type walkPool struct {
+	walker  *walker
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []*dirRead
+	closed  bool
+	results chan *dirRead
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// newWalkPool starts the workers of a walk. When notify is set, read
+// directories are sent to the results channel.
+func newWalkPool(w *walker, workers int, notify bool) *walkPool {
+	pool := &walkPool{walker: w, stop: make(chan struct{})}
+	pool.cond = sync.NewCond(&pool.mu)
+
+	if notify {
+		pool.results = make(chan *dirRead, workers)
+	}
+
+	pool.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go pool.work()
+	}
+
+	return pool
+}
+
+// schedule queues a directory to be read.
+func (p *walkPool) schedule(path string, info os.FileInfo) *dirRead {
+	dir := &dirRead{path: path, info: info, done: make(chan struct{})}
+
+	p.mu.Lock()
+	p.queue = append(p.queue, dir)
+	p.mu.Unlock()
+	p.cond.Signal()
+
+	return dir
+}
+
+func (p *walkPool) work() {
+	defer p.wg.Done()
+
+	for {
+		p.mu.Lock()
+		for len(p.queue) == 0 && !p.closed {
+			p.cond.Wait()
+		}
+
+		if p.closed {
+			p.mu.Unlock()
+			return
+		}
+
+		dir := p.queue[len(p.queue)-1]
+		p.queue = p.queue[:len(p.queue)-1]
+		p.mu.Unlock()
+
+		dir.entries, dir.err = p.walker.readDir(dir.path)
+		close(dir.done)
+
+		if p.results != nil {
+			select {
+			case p.results <- dir:
+			case <-p.stop:
+				return
+			}
+		}
+	}
+}
+
+// close stops the workers, discarding the directories left to read.
+func (p *walkPool) close() {
+	p.mu.Lock()
+	p.closed = true
+	p.queue = nil
+	p.mu.Unlock()
+
+	p.cond.Broadcast()
+	close(p.stop)
+	p.wg.Wait()
+}
@@ -0,0 +1,195 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestFollowSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require elevated privileges on Windows")
+	}
+
+	clear()
+
+	abs := Abs(testDir)
+	WriteTextFile(filepath.Join(abs, "x", "file.txt"), "12345")
+	os.Symlink("x", filepath.Join(abs, "alias"))
+	os.Symlink("..", filepath.Join(abs, "x", "loop"))
+	os.Symlink("nope", filepath.Join(abs, "dangling"))
+
+	contains := func(paths []string, rel string) bool {
+		for _, path := range paths {
+			if path == filepath.Join(abs, rel) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	paths, err := List(abs, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if contains(paths, "alias/file.txt") || !contains(paths, "alias") || !contains(paths, "dangling") {
+		t.Logf("Expected symlinks not to be followed by default, received %v", paths)
+		t.Fail()
+	}
+
+	follow := ListOptions{WalkOptions: WalkOptions{FollowSymlinks: true}}
+
+	// The loop points back at the listed directory, and alias at x,
+	// so each directory must only be walked once.
+	paths, err = ListWith(abs, true, follow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !contains(paths, "alias/file.txt") || !contains(paths, "alias/loop") || contains(paths, "alias/loop/x") {
+		t.Logf("Expected alias to be walked once, received %v", paths)
+		t.Fail()
+	}
+
+	if !contains(paths, "x") || contains(paths, "x/file.txt") {
+		t.Logf("Expected x to be listed but not walked again, received %v", paths)
+		t.Fail()
+	}
+
+	// Non-recursive lists always describe symlinks by their targets.
+	for _, options := range []ListOptions{{}, follow} {
+		dirs, _ := ListDirectoriesWith(abs, false, options)
+		if !contains(dirs, "alias") {
+			t.Logf("Expected non-recursive lists to list symlinked directories, received %v", dirs)
+			t.Fail()
+		}
+
+		files, _ := ListFilesWith(abs, false, options)
+		if contains(files, "alias") || !contains(files, "dangling") {
+			t.Logf("Expected non-recursive lists not to list symlinked directories as files, received %v", files)
+			t.Fail()
+		}
+	}
+
+	entries, err := ListEntries(abs, false, follow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range entries {
+		switch entry.Name {
+		case "alias":
+			if entry.Type != EntryDir || entry.Target != "x" || entry.Broken {
+				t.Logf("Unexpected followed symlink entry %+v", entry)
+				t.Fail()
+			}
+		case "dangling":
+			if entry.Type != EntrySymlink || entry.Target != "nope" || !entry.Broken {
+				t.Logf("Expected a broken symlink entry, received %+v", entry)
+				t.Fail()
+			}
+		}
+	}
+
+	// Symlinks count as the length of their target, unless followed.
+	size, err := ByteSize(abs)
+	if err != nil || size != 5+int64(len("x")+len("..")+len("nope")) {
+		t.Logf("Expected the size of the file and the symlinks, received %v (%v)", size, err)
+		t.Fail()
+	}
+
+	size, err = ByteSize(abs, WalkOptions{FollowSymlinks: true})
+	if err != nil || size != 5+int64(len("nope")) {
+		t.Logf("Expected the size of the file and the broken symlink, received %v (%v)", size, err)
+		t.Fail()
+	}
+
+	out := filepath.Join(abs, "..", "copy")
+	if err = CopyWith(filepath.Join(abs, "alias"), out, CopyOptions{WalkOptions: WalkOptions{FollowSymlinks: true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if content, err := ReadTextFile(filepath.Join(out, "file.txt")); err != nil || content != "12345" {
+		t.Logf("Expected the symlinked directory to be copied, received %q (%v)", content, err)
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestConcurrentWalk(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	for _, dir := range []string{"p", "q", "r"} {
+		for _, sub := range []string{"s1", "s2", "s10"} {
+			WriteTextFile(filepath.Join(abs, dir, sub, "file.txt"), dir+sub)
+			WriteTextFile(filepath.Join(abs, dir, sub+".txt"), sub)
+		}
+	}
+
+	expected, err := List(abs, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ordered := ListOptions{WalkOptions: WalkOptions{Workers: 4, Ordered: true}}
+	paths, err := ListWith(abs, true, ordered)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(paths, "\n") != strings.Join(expected, "\n") {
+		t.Logf("Expected the order of a sequential walk %v, received %v", expected, paths)
+		t.Fail()
+	}
+
+	unordered := ListOptions{WalkOptions: WalkOptions{Workers: 4}}
+	paths, err = ListWith(abs, true, unordered)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		if path != abs && !seen[filepath.Dir(path)] {
+			t.Logf("Expected %s to be listed after its directory", path)
+			t.Fail()
+		}
+
+		seen[path] = true
+	}
+
+	if len(seen) != len(expected) {
+		t.Logf("Expected %d paths, received %d", len(expected), len(seen))
+		t.Fail()
+	}
+
+	// Stopping early must not leave the workers blocked.
+	count := 0
+	err = ListFunc(abs, true, func(path string, info os.FileInfo) error {
+		count++
+		if count == 3 {
+			return StopList
+		}
+
+		return nil
+	}, unordered)
+
+	if err != nil || count != 3 {
+		t.Logf("Expected listing to stop after 3 paths, received %d (%v)", count, err)
+		t.Fail()
+	}
+
+	size, _ := ByteSize(abs)
+	if parallel, err := ByteSize(abs, unordered.WalkOptions); err != nil || parallel != size {
+		t.Logf("Expected a size of %d, received %d (%v)", size, parallel, err)
+		t.Fail()
+	}
+
+	clear()
+}
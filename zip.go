@@ -0,0 +1,380 @@
+package fsutil
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ZipOptions configures the behavior of ZipWith, ZipTo and ZipAdd.
+type ZipOptions struct {
+	// IncludeRoot includes the source directory itself as the top-level
+	// folder of the archive (i.e. `src/file.txt` instead of `file.txt`).
+	IncludeRoot bool
+
+	// Prefix is prepended to the name of every entry (i.e. "release/v1").
+	Prefix string
+
+	// VolumeSize splits the archive into volumes of at most VolumeSize
+	// bytes (`name.z01`, `name.z02`, ..., `name.zip`), which Unzip reads
+	// back transparently. The central directory is never split, so the
+	// last volume may exceed VolumeSize for archives with a very large
+	// number of entries. Only applies to ZipWith. Must be at least
+	// MinVolumeSize. Zero creates a single archive.
+	VolumeSize int64
+
+	// Password encrypts every file with WinZip AES-256 encryption (AE-2),
+	// which can be read by Unzip (see UnzipOptions.Password), 7-Zip and
+	// WinZip. Only the content is encrypted: entry names, sizes and
+	// modification times remain visible.
+	Password string
+
+	// Ignore excludes the files and directories matching gitignore
+	// rules (relative to the source) from the archive.
+	Ignore *IgnoreRules
+
+	// WalkOptions determine whether symlinks within the source are
+	// followed, storing their targets (symlinks are skipped otherwise),
+	// and whether the source is walked concurrently. Set Ordered along
+	// with Workers for archives with a deterministic entry order.
+	WalkOptions
+}
+
+// entryName determines the archive entry name of a path relative to the source.
+func (opts *ZipOptions) entryName(src string, rel string) string {
+	name := filepath.ToSlash(rel)
+
+	if name == "." {
+		name = filepath.Base(src)
+	} else if opts.IncludeRoot {
+		name = filepath.Base(src) + "/" + name
+	}
+
+	// The prefix is cleaned, so it cannot make names absolute (i.e. "/")
+	// or point outside of the archive (i.e. "../x").
+	prefix := strings.Trim(path.Clean("/"+filepath.ToSlash(opts.Prefix)), "/")
+	if len(prefix) > 0 {
+		name = prefix + "/" + name
+	}
+
+	return name
+}
+
+// zipSource is a file on disk destined for a zip archive.
+type zipSource struct {
+	Name string
+	Path string
+	Info os.FileInfo
+}
+
+// zipSources lists the files of src, named relative to src (see
+// ZipOptions). A single file is named after its base name. Directories,
+// symlinks, ignored paths and the excluded paths (i.e. the archive itself)
+// are not included.
+func zipSources(src string, opts *ZipOptions, exclude ...string) ([]zipSource, error) {
+	src = Abs(src)
+	sources := make([]zipSource, 0)
+	rules := opts.Ignore.walk(src)
+
+	err := walk(src, opts.WalkOptions, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		ignored, err := rules.ignored(path, info.IsDir())
+		if err != nil {
+			return err
+		}
+
+		if ignored {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		for _, excluded := range exclude {
+			if path == excluded {
+				return nil
+			}
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		sources = append(sources, zipSource{
+			Name: opts.entryName(src, rel),
+			Path: path,
+			Info: info,
+		})
+
+		return nil
+	})
+
+	return sources, err
+}
+
+// writeZipSource streams a file into the archive, preserving its mode
+// and modification time. It is encrypted when a password is set.
+func writeZipSource(writer *zip.Writer, source zipSource, opts *ZipOptions) (err error) {
+	header, err := zip.FileInfoHeader(source.Info)
+	if err != nil {
+		return err
+	}
+
+	header.Name = source.Name
+	header.Method = zip.Deflate
+
+	if len(opts.Password) > 0 {
+		file, err := os.Open(source.Path)
+		if err != nil {
+			return err
+		}
+		defer closeWithError(file, &err)
+
+		return writeAESEntry(writer, header, opts.Password, file)
+	}
+
+	w, err := writer.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	return copyFileTo(w, source.Path)
+}
+
+// ZipTo streams a zip archive of a file or directory to w (such as an
+// http.ResponseWriter). Entries are named relative to the source, unless
+// otherwise specified by the optional ZipOptions. Symlinks are not followed.
+func ZipTo(w io.Writer, src string, options ...ZipOptions) error {
+	opts := ZipOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	return zipTo(w, Abs(src), &opts)
+}
+
+func zipTo(w io.Writer, src string, opts *ZipOptions, exclude ...string) error {
+	sources, err := zipSources(src, opts, exclude...)
+	if err != nil {
+		return err
+	}
+
+	writer := zip.NewWriter(w)
+
+	for _, source := range sources {
+		if err = writeZipSource(writer, source, opts); err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+// ZipFS streams a zip archive of all regular files in fsys to w.
+// Entries are named by their path within fsys.
+func ZipFS(w io.Writer, fsys fs.FS) error {
+	writer := zip.NewWriter(w)
+
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+
+		header.Name = path
+		header.Method = zip.Deflate
+
+		entry, err := writer.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		return copyFSFileTo(entry, fsys, path)
+	})
+	if err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+func copyFSFileTo(w io.Writer, fsys fs.FS, path string) (err error) {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return err
+	}
+	defer closeWithError(file, &err)
+
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// UnzipFrom extracts a zip archive read from r, which holds size bytes
+// (such as a multipart.File), into the destination directory.
+// It accepts the same options as Unzip.
+func UnzipFrom(r io.ReaderAt, size int64, dest string, options ...UnzipOptions) error {
+	opts := UnzipOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+
+	return unzip(reader, Abs(dest), &opts)
+}
+
+// ZipAdd adds a file or directory to an existing zip archive, creating
+// the archive if it does not exist. Entries are named relative to the
+// source, just like Zip (see ZipOptions). Existing entries with the same
+// name are replaced, and all other entries are copied as-is, without
+// being recompressed.
+func ZipAdd(archive string, src string, options ...ZipOptions) error {
+	opts := ZipOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	archive = Abs(archive)
+
+	sources, err := zipSources(src, &opts, archive)
+	if err != nil {
+		return err
+	}
+
+	replaced := make(map[string]bool, len(sources))
+	for _, source := range sources {
+		replaced[source.Name] = true
+	}
+
+	return updateZip(archive, func(f *zip.File) (bool, error) {
+		return !replaced[f.Name], nil
+	}, func(writer *zip.Writer) error {
+		for _, source := range sources {
+			if err := writeZipSource(writer, source, &opts); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// ZipDelete removes all entries matching any of the glob patterns from a
+// zip archive (see UnzipOptions.Include for matching rules). The remaining
+// entries are copied as-is, without being recompressed.
+func ZipDelete(archive string, patterns ...string) error {
+	archive = Abs(archive)
+	if !Exists(archive) {
+		return errors.New(archive + " does not exist")
+	}
+
+	return updateZip(archive, func(f *zip.File) (bool, error) {
+		matched, err := matchEntry(f.Name, patterns...)
+		return !matched, err
+	}, nil)
+}
+
+// updateZip rewrites an archive into a temporary file, copying the raw
+// entries accepted by keep before appending new entries with add. The
+// temporary file replaces the archive once it is complete.
+func updateZip(archive string, keep func(*zip.File) (bool, error), add func(*zip.Writer) error) (err error) {
+	Mkdirp(filepath.Dir(archive))
+
+	tmp, err := os.CreateTemp(filepath.Dir(archive), "."+filepath.Base(archive)+"-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	writer := zip.NewWriter(tmp)
+
+	if Exists(archive) {
+		if err = copyZipEntries(writer, archive, keep); err != nil {
+			return err
+		}
+
+		if info, err := os.Stat(archive); err == nil {
+			tmp.Chmod(info.Mode())
+		}
+	}
+
+	if add != nil {
+		if err = add(writer); err != nil {
+			return err
+		}
+	}
+
+	if err = writer.Close(); err != nil {
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), archive)
+}
+
+func copyZipEntries(writer *zip.Writer, archive string, keep func(*zip.File) (bool, error)) (err error) {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer closeWithError(r, &err)
+
+	if err = writer.SetComment(r.Comment); err != nil {
+		return err
+	}
+
+	for _, f := range r.File {
+		ok, err := keep(f)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			continue
+		}
+
+		if err = writer.Copy(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
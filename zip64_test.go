@@ -0,0 +1,124 @@
+package fsutil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"testing/fstest"
+)
+
+// zip64Size exceeds the 4GiB limit of regular zip archives.
+const zip64Size int64 = 0xffffffff + 1024*1024
+
+func TestZip64Entries(t *testing.T) {
+	clear()
+
+	// More than 65535 entries requires a ZIP64 end of central directory.
+	count := 70000
+	fsys := fstest.MapFS{}
+	for i := 0; i < count; i++ {
+		fsys[fmt.Sprintf("%03d/%d.txt", i%100, i)] = &fstest.MapFile{Data: []byte("x")}
+	}
+
+	var buf bytes.Buffer
+	if err := ZipFS(&buf, fsys); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(Abs(testDir), "entries.zip")
+	Touch(archive, true)
+	if err := os.WriteFile(archive, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ArchiveList(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != count {
+		t.Logf("Expected %v entries, received %v", count, len(entries))
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestZip64LargeEntry(t *testing.T) {
+	// Compressing more than 4GiB of (sparse) data takes a few seconds.
+	if testing.Short() {
+		t.Skip("skipping large archive test in short mode")
+	}
+
+	// Files are not sparse by default on Windows (NTFS).
+	if runtime.GOOS == "windows" {
+		t.Skip("sparse files are not supported on Windows")
+	}
+
+	clear()
+
+	abs := Abs(testDir)
+	src := filepath.Join(abs, "src")
+	path := Touch(filepath.Join(src, "disk.img"), true)
+
+	// A sparse file does not occupy any significant disk space.
+	if err := os.Truncate(path, zip64Size); err != nil {
+		t.Fatal(err)
+	}
+
+	marker := []byte("end of image")
+	file, err := os.OpenFile(path, os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteAt(marker, zip64Size-int64(len(marker)))
+	file.Close()
+
+	tests := map[string]ZipOptions{
+		"single.zip": {},
+		"split.zip":  {VolumeSize: 1024 * 1024},
+	}
+
+	for name, opts := range tests {
+		archive := filepath.Join(abs, name)
+		if err = ZipWith(src, archive, opts); err != nil {
+			t.Fatal(err)
+		}
+
+		entries, err := ArchiveList(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(entries) != 1 || entries[0].Size != zip64Size {
+			t.Logf("%v: expected a single %v byte entry, received %+v", name, zip64Size, entries)
+			t.Fail()
+		}
+
+		// The entry is streamed, so the test does not need 4GiB of disk space.
+		r, err := OpenInArchive(archive, "disk.img")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		n, err := io.CopyN(io.Discard, r, zip64Size-int64(len(marker)))
+		data, _ := io.ReadAll(r)
+		r.Close()
+
+		if err != nil || n+int64(len(data)) != zip64Size {
+			t.Logf("%v: expected %v bytes, received %v (%v)", name, zip64Size, n+int64(len(data)), err)
+			t.Fail()
+		}
+
+		if !bytes.Equal(data, marker) {
+			t.Logf("%v: expected the entry to end with %q, received %q", name, marker, data)
+			t.Fail()
+		}
+	}
+
+	clear()
+}
@@ -0,0 +1,227 @@
+package fsutil
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func readArchiveEntry(archive string, name string) (string, error) {
+	r, err := OpenInArchive(archive, name)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	return string(data), err
+}
+
+func TestZipAddDelete(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	archive := filepath.Join(abs, "logs.zip")
+
+	err := writeTestZip(archive,
+		testZipEntry{Name: "app.log", Content: "old"},
+		testZipEntry{Name: "2020/app.log", Content: "2020"},
+		testZipEntry{Name: "2021/app.log", Content: "2021"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := filepath.Join(abs, "logs")
+	WriteTextFile(filepath.Join(src, "app.log"), "new")
+	WriteTextFile(filepath.Join(src, "2022", "app.log"), "2022")
+
+	if err = ZipAdd(archive, src); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ArchiveList(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 4 {
+		t.Logf("Expected 4 entries, received %v", len(entries))
+		t.Fail()
+	}
+
+	if data, _ := readArchiveEntry(archive, "app.log"); data != "new" {
+		t.Logf("Expected replaced entry content \"new\", received \"%v\"", data)
+		t.Fail()
+	}
+
+	if data, _ := readArchiveEntry(archive, "2021/app.log"); data != "2021" {
+		t.Logf("Expected copied entry content \"2021\", received \"%v\"", data)
+		t.Fail()
+	}
+
+	if data, _ := readArchiveEntry(archive, "2022/app.log"); data != "2022" {
+		t.Logf("Expected appended entry content \"2022\", received \"%v\"", data)
+		t.Fail()
+	}
+
+	if err = ZipDelete(archive, "2020/*", "2021/*"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err = ArchiveList(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 {
+		t.Logf("Expected 2 entries after deletion, received %v", entries)
+		t.Fail()
+	}
+
+	if err = ArchiveVerify(archive); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+
+	// Adding to a non-existent archive creates it.
+	if err = ZipAdd(filepath.Join(abs, "new.zip"), filepath.Join(src, "app.log")); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := readArchiveEntry(filepath.Join(abs, "new.zip"), "app.log"); data != "new" {
+		t.Logf("Expected entry content \"new\", received \"%v\"", data)
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestZipToUnzipFrom(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	src := filepath.Join(abs, "src")
+	content := "test content"
+
+	err := WriteTextFile(filepath.Join(src, "more", "test.txt"), content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = ZipTo(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(abs, "out")
+	err = UnzipFrom(bytes.NewReader(buf.Bytes()), int64(buf.Len()), out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ReadTextFile(filepath.Join(out, "more", "test.txt"))
+	if data != content {
+		t.Log("Extracted file contents do not match")
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestZipFS(t *testing.T) {
+	clear()
+
+	fsys := fstest.MapFS{
+		"test.txt":      {Data: []byte("test content")},
+		"more/test.txt": {Data: []byte("more content")},
+	}
+
+	var buf bytes.Buffer
+	if err := ZipFS(&buf, fsys); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(Abs(testDir), "out")
+	err := UnzipFrom(bytes.NewReader(buf.Bytes()), int64(buf.Len()), out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ReadTextFile(filepath.Join(out, "more", "test.txt"))
+	if data != "more content" {
+		t.Logf("Expected \"more content\", received \"%v\"", data)
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestZipRoot(t *testing.T) {
+	clear()
+
+	// The source path recurs within its own subdirectories.
+	src := "./" + testDir + "/src"
+	content := "test content"
+
+	err := WriteTextFile(filepath.Join(src, ".data", "a", "b", "src", "test.txt"), content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Zip(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(Abs(testDir), "src.zip")
+	if data, err := readArchiveEntry(archive, ".data/a/b/src/test.txt"); data != content {
+		t.Logf("Expected entry relative to the source, received error %v", err)
+		t.Fail()
+	}
+
+	err = ZipWith(src, archive, ZipOptions{IncludeRoot: true, Prefix: "release/v1/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := readArchiveEntry(archive, "release/v1/src/.data/a/b/src/test.txt"); data != content {
+		t.Logf("Expected prefixed entry including the root folder, received error %v", err)
+		t.Fail()
+	}
+
+	// Prefixes are cleaned.
+	prefixes := map[string]string{
+		"/":       ".data/a/b/src/test.txt",
+		"./x":     "x/.data/a/b/src/test.txt",
+		"../up/":  "up/.data/a/b/src/test.txt",
+		"a//b/./": "a/b/.data/a/b/src/test.txt",
+	}
+
+	for prefix, name := range prefixes {
+		if err = ZipWith(src, archive, ZipOptions{Prefix: prefix}); err != nil {
+			t.Fatal(err)
+		}
+
+		if data, err := readArchiveEntry(archive, name); data != content {
+			t.Logf("Expected the prefix %q to name the entry %v, received error %v", prefix, name, err)
+			t.Fail()
+		}
+	}
+
+	// Archives created inside the source do not include themselves.
+	err = Zip(src, filepath.Join(src, "self.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _ := ArchiveList(filepath.Join(src, "self.zip"))
+	if len(entries) != 1 {
+		t.Logf("Expected 1 entry, received %v", entries)
+		t.Fail()
+	}
+
+	clear()
+}
@@ -0,0 +1,471 @@
+package fsutil
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// ErrPasswordRequired is returned when reading an encrypted
+	// archive entry without a password.
+	ErrPasswordRequired = errors.New("password required for encrypted entry")
+
+	// ErrInvalidPassword is returned when the password of an
+	// encrypted archive entry is wrong.
+	ErrInvalidPassword = errors.New("invalid password for encrypted entry")
+)
+
+// WinZip AES encryption (https://www.winzip.com/en/support/aes-encryption/).
+const (
+	aesMethod       uint16 = 99
+	aesExtraID      uint16 = 0x9901
+	aesVersion      uint16 = 51
+	aesStrength256  byte   = 3
+	aesIterations          = 1000
+	aesVerifierLen         = 2
+	aesMACLen              = 10
+	flagEncrypted   uint16 = 0x1
+	flagDescriptor  uint16 = 0x8
+	flagUTF8        uint16 = 0x800
+	extTimeExtraID  uint16 = 0x5455
+	vendorVersionA1 uint16 = 1
+	vendorVersionA2 uint16 = 2
+)
+
+// aesKeyLen determines the AES key length of an encryption strength
+// (1: AES-128, 2: AES-192, 3: AES-256). The salt is half as long.
+func aesKeyLen(strength byte) int {
+	return 8 + 8*int(strength)
+}
+
+// deriveAESKeys derives the encryption key, authentication key and
+// password verifier of an entry from the password and its salt.
+func deriveAESKeys(password string, salt []byte, keyLen int) (key, macKey, verifier []byte) {
+	dk := pbkdf2SHA1([]byte(password), salt, aesIterations, 2*keyLen+aesVerifierLen)
+	return dk[:keyLen], dk[keyLen : 2*keyLen], dk[2*keyLen:]
+}
+
+// pbkdf2SHA1 implements PBKDF2 (RFC 8018) with HMAC-SHA1.
+func pbkdf2SHA1(password, salt []byte, iterations int, keyLen int) []byte {
+	prf := hmac.New(sha1.New, password)
+	blocks := (keyLen + prf.Size() - 1) / prf.Size()
+
+	dk := make([]byte, 0, blocks*prf.Size())
+	index := make([]byte, 4)
+
+	for block := 1; block <= blocks; block++ {
+		binary.BigEndian.PutUint32(index, uint32(block))
+
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(index)
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+
+		dk = append(dk, t...)
+	}
+
+	return dk[:keyLen]
+}
+
+// aesCTR is the AES counter mode used by WinZip, which (unlike
+// cipher.NewCTR) increments a little-endian counter starting at 1.
+type aesCTR struct {
+	block   cipher.Block
+	counter [aes.BlockSize]byte
+	stream  [aes.BlockSize]byte
+	pos     int
+}
+
+func newAESCTR(key []byte) (*aesCTR, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &aesCTR{block: block, pos: aes.BlockSize}, nil
+}
+
+func (c *aesCTR) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if c.pos == aes.BlockSize {
+			for j := range c.counter {
+				c.counter[j]++
+				if c.counter[j] != 0 {
+					break
+				}
+			}
+
+			c.block.Encrypt(c.stream[:], c.counter[:])
+			c.pos = 0
+		}
+
+		dst[i] = src[i] ^ c.stream[c.pos]
+		c.pos++
+	}
+}
+
+// aesExtra finds the AES extra field of an entry, returning the vendor
+// version, encryption strength and actual compression method.
+func aesExtra(extra []byte) (version uint16, strength byte, method uint16, err error) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		extra = extra[4:]
+
+		if size > len(extra) {
+			break
+		}
+
+		if id == aesExtraID && size >= 7 && extra[2] == 'A' && extra[3] == 'E' {
+			version = binary.LittleEndian.Uint16(extra)
+			strength = extra[4]
+			method = binary.LittleEndian.Uint16(extra[5:])
+
+			if strength < 1 || strength > 3 {
+				break
+			}
+
+			return version, strength, method, nil
+		}
+
+		extra = extra[size:]
+	}
+
+	return 0, 0, 0, fmt.Errorf("%w: invalid AES extra field", zip.ErrFormat)
+}
+
+// openZipFile opens an archive entry for reading, decrypting AES
+// encrypted entries with the password.
+func openZipFile(f *zip.File, password string) (io.ReadCloser, error) {
+	if f.Flags&flagEncrypted == 0 {
+		return f.Open()
+	}
+
+	if f.Method != aesMethod {
+		return nil, fmt.Errorf("%w: %s uses traditional zip encryption", zip.ErrAlgorithm, f.Name)
+	}
+
+	if len(password) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPasswordRequired, f.Name)
+	}
+
+	version, strength, method, err := aesExtra(f.Extra)
+	if err != nil {
+		return nil, err
+	}
+
+	if method != zip.Store && method != zip.Deflate {
+		return nil, zip.ErrAlgorithm
+	}
+
+	keyLen := aesKeyLen(strength)
+	saltLen := keyLen / 2
+
+	size := int64(f.CompressedSize64) - int64(saltLen+aesVerifierLen+aesMACLen)
+	if size < 0 {
+		return nil, zip.ErrFormat
+	}
+
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, saltLen+aesVerifierLen)
+	if _, err = io.ReadFull(raw, header); err != nil {
+		return nil, err
+	}
+
+	key, macKey, verifier := deriveAESKeys(password, header[:saltLen], keyLen)
+	if !bytes.Equal(verifier, header[saltLen:]) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPassword, f.Name)
+	}
+
+	stream, err := newAESCTR(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ar := &aesReader{
+		r:      io.LimitReader(raw, size),
+		raw:    raw,
+		stream: stream,
+		mac:    hmac.New(sha1.New, macKey),
+	}
+
+	rc := &aesFileReader{aes: ar, size: f.UncompressedSize64}
+
+	if method == zip.Deflate {
+		fr := flate.NewReader(ar)
+		rc.r, rc.closer = fr, fr
+	} else {
+		rc.r = ar
+	}
+
+	// AE-1 entries also store the CRC of the plaintext, AE-2 entries do not.
+	if version == vendorVersionA1 {
+		rc.crc, rc.sum = crc32.NewIEEE(), f.CRC32
+	}
+
+	return rc, nil
+}
+
+// aesReader decrypts the data of an entry, verifying the
+// authentication code that follows it once the end is reached.
+type aesReader struct {
+	r      io.Reader
+	raw    io.Reader
+	stream cipher.Stream
+	mac    hash.Hash
+	err    error
+}
+
+func (r *aesReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	n, err := r.r.Read(p)
+	r.mac.Write(p[:n])
+	r.stream.XORKeyStream(p[:n], p[:n])
+
+	if err == io.EOF {
+		expected := make([]byte, aesMACLen)
+		if _, err = io.ReadFull(r.raw, expected); err != nil {
+			r.err = err
+			return n, err
+		}
+
+		err = io.EOF
+		if !hmac.Equal(r.mac.Sum(nil)[:aesMACLen], expected) {
+			err = zip.ErrChecksum
+		}
+	}
+
+	r.err = err
+	return n, err
+}
+
+// aesFileReader reads the decompressed content of an encrypted entry.
+type aesFileReader struct {
+	r      io.Reader
+	aes    *aesReader
+	closer io.Closer
+	crc    hash.Hash32
+	sum    uint32
+	size   uint64
+	read   uint64
+	err    error
+}
+
+func (r *aesFileReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	n, err := r.r.Read(p)
+	r.read += uint64(n)
+	if r.crc != nil {
+		r.crc.Write(p[:n])
+	}
+
+	if err != nil {
+		// The decompressor may stop short of the authentication code,
+		// or fail on tampered content before reaching it.
+		if _, macErr := io.Copy(io.Discard, r.aes); macErr != nil {
+			err = macErr
+		} else if err == io.EOF && (r.read != r.size || (r.crc != nil && r.crc.Sum32() != r.sum)) {
+			err = zip.ErrChecksum
+		}
+	}
+
+	r.err = err
+	return n, err
+}
+
+func (r *aesFileReader) Close() error {
+	if r.closer != nil {
+		return r.closer.Close()
+	}
+
+	return nil
+}
+
+// writeAESEntry writes an entry encrypted with AES-256 (AE-2),
+// compressing it with the method of the header.
+func writeAESEntry(writer *zip.Writer, header *zip.FileHeader, password string, r io.Reader) error {
+	keyLen := aesKeyLen(aesStrength256)
+
+	salt := make([]byte, keyLen/2)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+
+	key, macKey, verifier := deriveAESKeys(password, salt, keyLen)
+
+	stream, err := newAESCTR(key)
+	if err != nil {
+		return err
+	}
+
+	method := header.Method
+	if method != zip.Store && method != zip.Deflate {
+		return zip.ErrAlgorithm
+	}
+
+	extra := make([]byte, 11)
+	binary.LittleEndian.PutUint16(extra, aesExtraID)
+	binary.LittleEndian.PutUint16(extra[2:], 7)
+	binary.LittleEndian.PutUint16(extra[4:], vendorVersionA2)
+	copy(extra[6:], "AE")
+	extra[8] = aesStrength256
+	binary.LittleEndian.PutUint16(extra[9:], method)
+
+	// CreateRaw does not prepare the header like CreateHeader does.
+	if !header.Modified.IsZero() {
+		header.ModifiedDate, header.ModifiedTime = msDosTime(header.Modified)
+		extra = append(extra, extTimeExtra(header.Modified)...)
+	}
+
+	if utf8.ValidString(header.Name) && !isASCII(header.Name) {
+		header.Flags |= flagUTF8
+	}
+
+	// The sizes are only known once the entry is written,
+	// so they are stored in a data descriptor.
+	header.Method = aesMethod
+	header.Flags |= flagEncrypted | flagDescriptor
+	header.CreatorVersion = header.CreatorVersion&0xff00 | aesVersion
+	header.ReaderVersion = aesVersion
+	header.CRC32 = 0
+	header.Extra = append(header.Extra, extra...)
+
+	w, err := writer.CreateRaw(header)
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write(append(salt, verifier...)); err != nil {
+		return err
+	}
+
+	aw := &aesWriter{w: w, stream: stream, mac: hmac.New(sha1.New, macKey)}
+
+	var cw io.WriteCloser = aw
+	if method == zip.Deflate {
+		if cw, err = flate.NewWriter(aw, flate.DefaultCompression); err != nil {
+			return err
+		}
+	}
+
+	size, err := io.Copy(cw, r)
+	if err != nil {
+		return err
+	}
+
+	if err = cw.Close(); err != nil {
+		return err
+	}
+
+	if _, err = w.Write(aw.mac.Sum(nil)[:aesMACLen]); err != nil {
+		return err
+	}
+
+	// The writer retains the header, and reads the sizes from it when
+	// writing the data descriptor and central directory.
+	header.CompressedSize64 = uint64(len(salt)+len(verifier)+aesMACLen) + aw.written
+	header.UncompressedSize64 = uint64(size)
+	header.CompressedSize = uint32(minUint64(header.CompressedSize64, math.MaxUint32))
+	header.UncompressedSize = uint32(minUint64(header.UncompressedSize64, math.MaxUint32))
+
+	return nil
+}
+
+// aesWriter encrypts and authenticates the data of an entry.
+type aesWriter struct {
+	w       io.Writer
+	stream  cipher.Stream
+	mac     hash.Hash
+	buf     []byte
+	written uint64
+}
+
+func (w *aesWriter) Write(p []byte) (int, error) {
+	if cap(w.buf) < len(p) {
+		w.buf = make([]byte, len(p))
+	}
+
+	buf := w.buf[:len(p)]
+	w.stream.XORKeyStream(buf, p)
+	w.mac.Write(buf)
+
+	n, err := w.w.Write(buf)
+	w.written += uint64(n)
+	return n, err
+}
+
+func (w *aesWriter) Close() error {
+	return nil
+}
+
+// msDosTime converts a time to the MS-DOS date and time format.
+func msDosTime(t time.Time) (uint16, uint16) {
+	date := uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	clock := uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	return date, clock
+}
+
+// extTimeExtra creates an extended timestamp extra field,
+// storing the modification time in seconds since the epoch.
+func extTimeExtra(t time.Time) []byte {
+	extra := make([]byte, 9)
+	binary.LittleEndian.PutUint16(extra, extTimeExtraID)
+	binary.LittleEndian.PutUint16(extra[2:], 5)
+	extra[4] = 1
+	binary.LittleEndian.PutUint32(extra[5:], uint32(t.Unix()))
+	return extra
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
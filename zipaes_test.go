@@ -0,0 +1,137 @@
+package fsutil
+
+import (
+	"archive/zip"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPBKDF2(t *testing.T) {
+	// RFC 6070 test vectors.
+	key := pbkdf2SHA1([]byte("password"), []byte("salt"), 1, 20)
+	if hex.EncodeToString(key) != "0c60c80f961f0e71f3a9b524af6012062fe037a6" {
+		t.Logf("Unexpected key for 1 iteration: %x", key)
+		t.Fail()
+	}
+
+	key = pbkdf2SHA1([]byte("passwordPASSWORDpassword"), []byte("saltSALTsaltSALTsaltSALTsaltSALTsalt"), 4096, 25)
+	if hex.EncodeToString(key) != "3d2eec4fe41c849b80c8d83662c0e44a8b291a964cf2f07038" {
+		t.Logf("Unexpected key for 4096 iterations: %x", key)
+		t.Fail()
+	}
+}
+
+func TestZipPassword(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	src := filepath.Join(abs, "src")
+	archive := filepath.Join(abs, "secret.zip")
+	content := "top secret content"
+
+	WriteTextFile(filepath.Join(src, "secret.txt"), content)
+	WriteTextFile(filepath.Join(src, "more", "empty.txt"), "")
+
+	err := ZipWith(src, archive, ZipOptions{Password: "pa$$word"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ArchiveList(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range entries {
+		if !entry.Encrypted || entry.Method != aesMethod {
+			t.Logf("Expected %v to be AES encrypted, received method %v", entry.Name, entry.Method)
+			t.Fail()
+		}
+	}
+
+	out := filepath.Join(abs, "out")
+	if err = Unzip(archive, out); !errors.Is(err, ErrPasswordRequired) {
+		t.Logf("Expected ErrPasswordRequired, received %v", err)
+		t.Fail()
+	}
+
+	if err = Unzip(archive, out, UnzipOptions{Password: "wrong"}); !errors.Is(err, ErrInvalidPassword) {
+		t.Logf("Expected ErrInvalidPassword, received %v", err)
+		t.Fail()
+	}
+
+	if err = Unzip(archive, out, UnzipOptions{Password: "pa$$word"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ReadTextFile(filepath.Join(out, "secret.txt"))
+	if data != content {
+		t.Logf("Expected \"%v\", received \"%v\"", content, data)
+		t.Fail()
+	}
+
+	info, err := os.Stat(filepath.Join(out, "more", "empty.txt"))
+	if err != nil || info.Size() != 0 {
+		t.Logf("Expected an empty file, received %v", err)
+		t.Fail()
+	}
+
+	if data, _ := readArchiveEntry(archive, "secret.txt"); data == content {
+		t.Log("Expected encrypted entry to be unreadable without a password")
+		t.Fail()
+	}
+
+	if err = ArchiveVerify(archive, "pa$$word"); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestZipPasswordTampered(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	src := filepath.Join(abs, "secret.txt")
+	archive := filepath.Join(abs, "secret.zip")
+
+	WriteTextFile(src, "top secret content")
+
+	err := ZipWith(src, archive, ZipOptions{Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offset, err := r.File[0].DataOffset()
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip a bit of the encrypted content, following the salt and verifier.
+	data[offset+20] ^= 1
+	if err = os.WriteFile(archive, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ArchiveVerify(archive, "password"); !errors.Is(err, zip.ErrChecksum) {
+		t.Logf("Expected zip.ErrChecksum, received %v", err)
+		t.Fail()
+	}
+
+	clear()
+}
@@ -0,0 +1,711 @@
+package fsutil
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Split (multi-volume) zip archives follow the PKWARE layout: data is
+// chunked into `name.z01`, `name.z02`, ... volumes, while the final
+// volume (`name.zip`) holds the remaining data and the central directory.
+// Every central directory offset is relative to the volume it points into.
+
+// MinVolumeSize is the smallest volume size allowed for split archives.
+const MinVolumeSize int64 = 64 * 1024
+
+const (
+	splitSignature          = 0x08074b50
+	directoryHeaderSig      = 0x02014b50
+	directoryEndSig         = 0x06054b50
+	directory64EndSig       = 0x06064b50
+	directory64LocSig       = 0x07064b50
+	directoryHeaderLen      = 46
+	directoryEndLen         = 22
+	directory64EndLen       = 56
+	directory64LocLen       = 20
+	zip64ExtraID            = 0x0001
+	uint16max               = 0xffff
+	uint32max               = 0xffffffff
+	maxDirectoryEndSearched = 65 * 1024
+)
+
+// directoryEnd holds the end of central directory values of a zip archive.
+// Positions are relative to the start of the volume holding each record.
+type directoryEnd struct {
+	disk      uint32
+	dirDisk   uint32
+	records   uint64
+	dirSize   uint64
+	dirOffset uint64
+	comment   []byte
+
+	// position of the end of central directory record
+	endPos int64
+
+	// zip64 records, when present (locPos < 0 otherwise)
+	locPos   int64
+	end64Pos uint64
+	end64Vol uint32
+}
+
+// readDirectoryEnd locates and parses the end of central directory
+// records at the end of r.
+func readDirectoryEnd(r io.ReaderAt, size int64) (*directoryEnd, error) {
+	n := int64(maxDirectoryEndSearched)
+	if n > size {
+		n = size
+	}
+
+	buf := make([]byte, n)
+	if _, err := r.ReadAt(buf, size-n); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	p := -1
+	for i := len(buf) - directoryEndLen; i >= 0; i-- {
+		if binary.LittleEndian.Uint32(buf[i:]) == directoryEndSig {
+			p = i
+			break
+		}
+	}
+
+	if p < 0 {
+		return nil, zip.ErrFormat
+	}
+
+	b := buf[p:]
+	d := &directoryEnd{
+		disk:      uint32(binary.LittleEndian.Uint16(b[4:])),
+		dirDisk:   uint32(binary.LittleEndian.Uint16(b[6:])),
+		records:   uint64(binary.LittleEndian.Uint16(b[10:])),
+		dirSize:   uint64(binary.LittleEndian.Uint32(b[12:])),
+		dirOffset: uint64(binary.LittleEndian.Uint32(b[16:])),
+		endPos:    size - n + int64(p),
+		locPos:    -1,
+	}
+
+	commentLen := int(binary.LittleEndian.Uint16(b[20:]))
+	if directoryEndLen+commentLen <= len(b) {
+		d.comment = b[directoryEndLen : directoryEndLen+commentLen]
+	}
+
+	if d.endPos < directory64LocLen {
+		return d, nil
+	}
+
+	loc := make([]byte, directory64LocLen)
+	if _, err := r.ReadAt(loc, d.endPos-directory64LocLen); err != nil {
+		return nil, err
+	}
+
+	if binary.LittleEndian.Uint32(loc) == directory64LocSig {
+		d.locPos = d.endPos - directory64LocLen
+		d.end64Vol = binary.LittleEndian.Uint32(loc[4:])
+		d.end64Pos = binary.LittleEndian.Uint64(loc[8:])
+	}
+
+	return d, nil
+}
+
+// readDirectory64End applies the zip64 end of central directory values.
+func (d *directoryEnd) readDirectory64End(r io.ReaderAt, offset int64) error {
+	b := make([]byte, directory64EndLen)
+	if _, err := r.ReadAt(b, offset); err != nil {
+		return err
+	}
+
+	if binary.LittleEndian.Uint32(b) != directory64EndSig {
+		return zip.ErrFormat
+	}
+
+	d.disk = binary.LittleEndian.Uint32(b[16:])
+	d.dirDisk = binary.LittleEndian.Uint32(b[20:])
+	d.records = binary.LittleEndian.Uint64(b[32:])
+	d.dirSize = binary.LittleEndian.Uint64(b[40:])
+	d.dirOffset = binary.LittleEndian.Uint64(b[48:])
+
+	return nil
+}
+
+// directoryHeader is a central directory record, with any zip64 values resolved.
+type directoryHeader struct {
+	raw              []byte
+	compressedSize   uint64
+	uncompressedSize uint64
+	offset           uint64
+	disk             uint32
+
+	// position of the zip64 offset value within raw, or -1
+	offsetPos int
+}
+
+// parseDirectory parses the records of a central directory.
+func parseDirectory(b []byte) ([]*directoryHeader, error) {
+	headers := make([]*directoryHeader, 0)
+
+	for len(b) > 0 {
+		if len(b) < directoryHeaderLen || binary.LittleEndian.Uint32(b) != directoryHeaderSig {
+			return nil, zip.ErrFormat
+		}
+
+		nameLen := int(binary.LittleEndian.Uint16(b[28:]))
+		extraLen := int(binary.LittleEndian.Uint16(b[30:]))
+		commentLen := int(binary.LittleEndian.Uint16(b[32:]))
+		size := directoryHeaderLen + nameLen + extraLen + commentLen
+		if len(b) < size {
+			return nil, zip.ErrFormat
+		}
+
+		h := &directoryHeader{
+			raw:              b[:size],
+			compressedSize:   uint64(binary.LittleEndian.Uint32(b[20:])),
+			uncompressedSize: uint64(binary.LittleEndian.Uint32(b[24:])),
+			offset:           uint64(binary.LittleEndian.Uint32(b[42:])),
+			disk:             uint32(binary.LittleEndian.Uint16(b[34:])),
+			offsetPos:        -1,
+		}
+
+		extra := directoryHeaderLen + nameLen
+		for p := extra; p+4 <= extra+extraLen; {
+			id := binary.LittleEndian.Uint16(b[p:])
+			n := int(binary.LittleEndian.Uint16(b[p+2:]))
+			if p+4+n > extra+extraLen {
+				return nil, zip.ErrFormat
+			}
+
+			if id == zip64ExtraID {
+				h.readZip64(b[:size], p+4, p+4+n)
+			}
+			p += 4 + n
+		}
+
+		headers = append(headers, h)
+		b = b[size:]
+	}
+
+	return headers, nil
+}
+
+// readZip64 reads the zip64 extra field values, which are only
+// present for the fields whose 32-bit values are maxed out.
+func (h *directoryHeader) readZip64(b []byte, start int, end int) {
+	next := func() (int, bool) {
+		if start+8 > end {
+			return -1, false
+		}
+		start += 8
+		return start - 8, true
+	}
+
+	if h.uncompressedSize == uint32max {
+		if p, ok := next(); ok {
+			h.uncompressedSize = binary.LittleEndian.Uint64(b[p:])
+		}
+	}
+
+	if h.compressedSize == uint32max {
+		if p, ok := next(); ok {
+			h.compressedSize = binary.LittleEndian.Uint64(b[p:])
+		}
+	}
+
+	if h.offset == uint32max {
+		if p, ok := next(); ok {
+			h.offset = binary.LittleEndian.Uint64(b[p:])
+			h.offsetPos = p
+		}
+	}
+
+	if h.disk == uint16max && start+4 <= end {
+		h.disk = binary.LittleEndian.Uint32(b[start:])
+	}
+}
+
+// volumes describes the start offset of each volume within the
+// concatenation of all volumes.
+type volumes []int64
+
+// locate identifies the volume holding an absolute offset,
+// along with the offset relative to that volume.
+func (v volumes) locate(offset int64) (int, int64) {
+	i := sort.Search(len(v), func(i int) bool { return v[i] > offset }) - 1
+	return i, offset - v[i]
+}
+
+// splitZip splits a single zip archive into volumes of at most
+// size bytes (the central directory is kept whole in the last volume).
+// The volumes are named after dest, which receives the last volume.
+// The source archive is removed.
+func splitZip(src string, dest string, size int64) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err == nil && info.Size() > size {
+		err = writeVolumes(file, info.Size(), dest, size)
+	}
+
+	closeWithError(file, &err)
+	if err != nil {
+		return err
+	}
+
+	// A single volume is a regular zip archive.
+	if info.Size() <= size {
+		return os.Rename(src, dest)
+	}
+
+	return os.Remove(src)
+}
+
+func writeVolumes(file io.ReaderAt, fileSize int64, dest string, size int64) error {
+	d, err := readDirectoryEnd(file, fileSize)
+	if err != nil {
+		return err
+	}
+
+	if d.locPos >= 0 {
+		if err = d.readDirectory64End(file, int64(d.end64Pos)); err != nil {
+			return err
+		}
+	}
+
+	// The tail (central directory and end records) is rewritten in memory.
+	// Every position is shifted by the split signature preceding the data.
+	dirOffset := int64(d.dirOffset)
+	tail := make([]byte, fileSize-dirOffset)
+	if _, err = file.ReadAt(tail, dirOffset); err != nil {
+		return err
+	}
+
+	total := fileSize + 4
+	starts := volumes{0}
+	for next := size; next < dirOffset+4; next += size {
+		starts = append(starts, next)
+	}
+
+	if total-starts[len(starts)-1] > size && starts[len(starts)-1] < dirOffset+4 {
+		starts = append(starts, dirOffset+4)
+	}
+
+	last := len(starts) - 1
+	if last >= uint16max {
+		return fmt.Errorf("split archive requires too many volumes (%d)", last+1)
+	}
+
+	relative := func(offset int64) uint64 {
+		return uint64(offset - starts[last])
+	}
+
+	headers, err := parseDirectory(tail[:d.dirSize])
+	if err != nil {
+		return err
+	}
+
+	for _, h := range headers {
+		disk, offset := starts.locate(int64(h.offset) + 4)
+
+		binary.LittleEndian.PutUint16(h.raw[34:], uint16(disk))
+		if h.offsetPos >= 0 {
+			binary.LittleEndian.PutUint64(h.raw[h.offsetPos:], uint64(offset))
+		} else {
+			binary.LittleEndian.PutUint32(h.raw[42:], uint32(offset))
+		}
+	}
+
+	if d.locPos >= 0 {
+		end64 := tail[int64(d.end64Pos)-dirOffset:]
+		binary.LittleEndian.PutUint32(end64[16:], uint32(last))
+		binary.LittleEndian.PutUint32(end64[20:], uint32(last))
+		binary.LittleEndian.PutUint64(end64[48:], relative(dirOffset+4))
+
+		loc := tail[d.locPos-dirOffset:]
+		binary.LittleEndian.PutUint32(loc[4:], uint32(last))
+		binary.LittleEndian.PutUint64(loc[8:], relative(int64(d.end64Pos)+4))
+		binary.LittleEndian.PutUint32(loc[16:], uint32(last+1))
+	}
+
+	end := tail[d.endPos-dirOffset:]
+	binary.LittleEndian.PutUint16(end[4:], uint16(last))
+	binary.LittleEndian.PutUint16(end[6:], uint16(last))
+	if binary.LittleEndian.Uint32(end[16:]) != uint32max {
+		binary.LittleEndian.PutUint32(end[16:], uint32(relative(dirOffset+4)))
+	}
+
+	signature := make([]byte, 4)
+	binary.LittleEndian.PutUint32(signature, splitSignature)
+
+	r := io.MultiReader(
+		bytes.NewReader(signature),
+		io.NewSectionReader(file, 0, dirOffset),
+		bytes.NewReader(tail),
+	)
+
+	for i, name := range volumeNames(dest, last+1) {
+		n := total - starts[i]
+		if i < last {
+			n = starts[i+1] - starts[i]
+		}
+
+		if err = writeVolume(name, r, n); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// volumeNames generates the names of split archive volumes.
+func volumeNames(dest string, count int) []string {
+	base := strings.TrimSuffix(dest, filepath.Ext(dest))
+	names := make([]string, count)
+
+	for i := 0; i < count-1; i++ {
+		names[i] = fmt.Sprintf("%s.z%02d", base, i+1)
+	}
+	names[count-1] = dest
+
+	return names
+}
+
+func writeVolume(name string, r io.Reader, n int64) (err error) {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer closeWithError(file, &err)
+
+	_, err = io.CopyN(file, r, n)
+	return err
+}
+
+// splitVolumes lists the volumes preceding the last volume of a split
+// archive, in order (`name.z01`, `name.z02`, ...).
+func splitVolumes(path string, count int) []string {
+	names := volumeNames(path, count+1)
+	return names[:count]
+}
+
+// removeVolumes removes the volumes of a previous split archive.
+func removeVolumes(path string) error {
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+
+	for i := 1; ; i++ {
+		volume := fmt.Sprintf("%s.z%02d", base, i)
+		if !IsFile(volume) {
+			return nil
+		}
+
+		if err := os.Remove(volume); err != nil {
+			return err
+		}
+	}
+}
+
+// zipArchive is a zip archive opened from one or more volumes.
+type zipArchive struct {
+	*zip.Reader
+	files []*os.File
+}
+
+func (z *zipArchive) Close() error {
+	var err error
+	for _, file := range z.files {
+		closeWithError(file, &err)
+	}
+	return err
+}
+
+func (z *zipArchive) open(path string) (io.ReaderAt, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	z.files = append(z.files, file)
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return file, info.Size(), nil
+}
+
+// openZip opens a regular or split zip archive. Split archives are
+// identified by the disk number of their end of central directory record.
+func openZip(path string) (*zipArchive, error) {
+	z := &zipArchive{}
+
+	r, size, err := z.open(path)
+	if err == nil {
+		z.Reader, err = openVolumes(z, path, r, size)
+	}
+
+	if err != nil {
+		z.Close()
+		return nil, err
+	}
+
+	return z, nil
+}
+
+func openVolumes(z *zipArchive, path string, r io.ReaderAt, size int64) (*zip.Reader, error) {
+	d, err := readDirectoryEnd(r, size)
+	if err != nil || d.disk == 0 {
+		return zip.NewReader(r, size)
+	}
+
+	readers := make([]io.ReaderAt, 0, d.disk+1)
+	sizes := make([]int64, 0, d.disk+1)
+
+	for _, volume := range splitVolumes(path, int(d.disk)) {
+		vr, vsize, err := z.open(volume)
+		if err != nil {
+			return nil, err
+		}
+
+		readers = append(readers, vr)
+		sizes = append(sizes, vsize)
+	}
+
+	return joinVolumes(append(readers, r), append(sizes, size))
+}
+
+// joinVolumes reads the volumes of a split archive as a single archive.
+// The volumes are concatenated, followed by a rewritten copy of the
+// central directory whose offsets are relative to the concatenation.
+// (The original central directory is simply ignored by the reader.)
+func joinVolumes(readers []io.ReaderAt, sizes []int64) (*zip.Reader, error) {
+	joined := newMultiReaderAt(readers, sizes)
+	starts := joined.starts
+	last := len(readers) - 1
+
+	d, err := readDirectoryEnd(readers[last], sizes[last])
+	if err != nil {
+		return nil, err
+	}
+
+	if d.locPos >= 0 {
+		if int(d.end64Vol) > last {
+			return nil, zip.ErrFormat
+		}
+
+		if err = d.readDirectory64End(joined, starts[d.end64Vol]+int64(d.end64Pos)); err != nil {
+			return nil, err
+		}
+	}
+
+	if int(d.dirDisk) > last {
+		return nil, zip.ErrFormat
+	}
+
+	// The central directory must lie within the volumes, which also
+	// prevents allocating an arbitrary amount of memory for it.
+	available := uint64(joined.size - starts[d.dirDisk])
+	if d.dirOffset > available || d.dirSize > available-d.dirOffset {
+		return nil, zip.ErrFormat
+	}
+
+	dir := make([]byte, d.dirSize)
+	if _, err = joined.ReadAt(dir, starts[d.dirDisk]+int64(d.dirOffset)); err != nil {
+		return nil, err
+	}
+
+	headers, err := parseDirectory(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, h := range headers {
+		if int(h.disk) > last {
+			return nil, zip.ErrFormat
+		}
+
+		h.offset += uint64(starts[h.disk])
+		buf.Write(h.rebase())
+	}
+
+	dirOffset := joined.size
+	dirSize := uint64(buf.Len())
+	end64Pos := dirOffset + int64(dirSize)
+
+	// zip64 end of central directory record
+	writeUint32(&buf, directory64EndSig)
+	writeUint64(&buf, directory64EndLen-12)
+	writeUint16(&buf, 45)
+	writeUint16(&buf, 45)
+	writeUint32(&buf, 0)
+	writeUint32(&buf, 0)
+	writeUint64(&buf, uint64(len(headers)))
+	writeUint64(&buf, uint64(len(headers)))
+	writeUint64(&buf, dirSize)
+	writeUint64(&buf, uint64(dirOffset))
+
+	// zip64 end of central directory locator
+	writeUint32(&buf, directory64LocSig)
+	writeUint32(&buf, 0)
+	writeUint64(&buf, uint64(end64Pos))
+	writeUint32(&buf, 1)
+
+	// end of central directory record
+	writeUint32(&buf, directoryEndSig)
+	writeUint16(&buf, 0)
+	writeUint16(&buf, 0)
+	writeUint16(&buf, uint16max)
+	writeUint16(&buf, uint16max)
+	writeUint32(&buf, uint32max)
+	writeUint32(&buf, uint32max)
+	writeUint16(&buf, uint16(len(d.comment)))
+	buf.Write(d.comment)
+
+	tail := buf.Bytes()
+	r := newMultiReaderAt(
+		append(readers, bytes.NewReader(tail)),
+		append(sizes, int64(len(tail))),
+	)
+
+	return zip.NewReader(r, r.size)
+}
+
+// rebase rebuilds the record for a single volume (disk 0),
+// storing the sizes and offset as zip64 values when necessary.
+func (h *directoryHeader) rebase() []byte {
+	nameLen := int(binary.LittleEndian.Uint16(h.raw[28:]))
+	extraLen := int(binary.LittleEndian.Uint16(h.raw[30:]))
+	extraStart := directoryHeaderLen + nameLen
+	extraEnd := extraStart + extraLen
+
+	var zip64 bytes.Buffer
+	fixed := append([]byte{}, h.raw[:directoryHeaderLen]...)
+
+	if h.uncompressedSize >= uint32max {
+		binary.LittleEndian.PutUint32(fixed[24:], uint32max)
+		writeUint64(&zip64, h.uncompressedSize)
+	} else {
+		binary.LittleEndian.PutUint32(fixed[24:], uint32(h.uncompressedSize))
+	}
+
+	if h.compressedSize >= uint32max {
+		binary.LittleEndian.PutUint32(fixed[20:], uint32max)
+		writeUint64(&zip64, h.compressedSize)
+	} else {
+		binary.LittleEndian.PutUint32(fixed[20:], uint32(h.compressedSize))
+	}
+
+	if h.offset >= uint32max {
+		binary.LittleEndian.PutUint32(fixed[42:], uint32max)
+		writeUint64(&zip64, h.offset)
+	} else {
+		binary.LittleEndian.PutUint32(fixed[42:], uint32(h.offset))
+	}
+
+	binary.LittleEndian.PutUint16(fixed[34:], 0)
+
+	// Copy every extra field except the original zip64 field.
+	var extra bytes.Buffer
+	if zip64.Len() > 0 {
+		writeUint16(&extra, zip64ExtraID)
+		writeUint16(&extra, uint16(zip64.Len()))
+		extra.Write(zip64.Bytes())
+	}
+
+	for p := extraStart; p+4 <= extraEnd; {
+		n := int(binary.LittleEndian.Uint16(h.raw[p+2:]))
+		if p+4+n > extraEnd {
+			break
+		}
+
+		if binary.LittleEndian.Uint16(h.raw[p:]) != zip64ExtraID {
+			extra.Write(h.raw[p : p+4+n])
+		}
+		p += 4 + n
+	}
+
+	binary.LittleEndian.PutUint16(fixed[30:], uint16(extra.Len()))
+
+	record := append(fixed, h.raw[directoryHeaderLen:extraStart]...)
+	record = append(record, extra.Bytes()...)
+	return append(record, h.raw[extraEnd:]...)
+}
+
+func writeUint16(buf *bytes.Buffer, v uint16) {
+	binary.Write(buf, binary.LittleEndian, v)
+}
+
+func writeUint32(buf *bytes.Buffer, v uint32) {
+	binary.Write(buf, binary.LittleEndian, v)
+}
+
+func writeUint64(buf *bytes.Buffer, v uint64) {
+	binary.Write(buf, binary.LittleEndian, v)
+}
+
+// multiReaderAt reads a sequence of readers as a single io.ReaderAt.
+type multiReaderAt struct {
+	readers []io.ReaderAt
+	starts  volumes
+	size    int64
+}
+
+func newMultiReaderAt(readers []io.ReaderAt, sizes []int64) *multiReaderAt {
+	m := &multiReaderAt{readers: readers, starts: make(volumes, len(readers))}
+
+	for i, size := range sizes {
+		m.starts[i] = m.size
+		m.size += size
+	}
+
+	return m
+}
+
+func (m *multiReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	read := 0
+	for len(p) > 0 {
+		if off >= m.size {
+			return read, io.EOF
+		}
+
+		i, rel := m.starts.locate(off)
+
+		end := m.size
+		if i+1 < len(m.starts) {
+			end = m.starts[i+1]
+		}
+
+		chunk := p
+		if int64(len(chunk)) > end-off {
+			chunk = chunk[:end-off]
+		}
+
+		n, err := m.readers[i].ReadAt(chunk, rel)
+		read += n
+		off += int64(n)
+		p = p[n:]
+
+		if err != nil && err != io.EOF {
+			return read, err
+		}
+
+		if n < len(chunk) {
+			return read, io.ErrUnexpectedEOF
+		}
+	}
+
+	return read, nil
+}
@@ -0,0 +1,140 @@
+package fsutil
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestZipVolumes(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	src := filepath.Join(abs, "src")
+	Mkdirp(src)
+
+	// Random content does not compress, so the archive must be split.
+	random := rand.New(rand.NewSource(1))
+	contents := make(map[string][]byte)
+	for _, name := range []string{"a.bin", "b.bin", "more/c.bin"} {
+		data := make([]byte, 100*1024)
+		random.Read(data)
+		contents[name] = data
+
+		Touch(filepath.Join(src, name), true)
+		if err := os.WriteFile(filepath.Join(src, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	archive := filepath.Join(abs, "backup.zip")
+	err := ZipWith(src, archive, ZipOptions{VolumeSize: MinVolumeSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	volumes, _ := filepath.Glob(filepath.Join(abs, "backup.z*"))
+	if len(volumes) < 5 {
+		t.Logf("Expected at least 5 volumes, received %v", volumes)
+		t.Fail()
+	}
+
+	for _, volume := range volumes {
+		if size, _ := ByteSize(volume); size > MinVolumeSize {
+			t.Logf("Volume %v exceeds the volume size (%v bytes)", volume, size)
+			t.Fail()
+		}
+	}
+
+	if err = ArchiveVerify(archive); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+
+	out := filepath.Join(abs, "out")
+	if err = Extract(archive, out); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range contents {
+		extracted, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil || !bytes.Equal(extracted, data) {
+			t.Logf("Extracted %v does not match (%v)", name, err)
+			t.Fail()
+		}
+	}
+
+	// Small archives are not split.
+	err = ZipWith(filepath.Join(src, "more"), archive, ZipOptions{VolumeSize: 10 * MinVolumeSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	volumes, _ = filepath.Glob(filepath.Join(abs, "backup.z*"))
+	if len(volumes) != 1 {
+		t.Logf("Expected a single archive, received %v", volumes)
+		t.Fail()
+	}
+
+	if err = ZipWith(src, archive, ZipOptions{VolumeSize: 1024}); err == nil {
+		t.Log("Expected an error for a volume size below the minimum.")
+		t.Fail()
+	}
+
+	clear()
+}
+
+func TestZipVolumesMalformed(t *testing.T) {
+	clear()
+
+	abs := Abs(testDir)
+	Mkdirp(abs)
+
+	// A central directory record whose zip64 extra field
+	// claims more data than the record holds.
+	record := make([]byte, directoryHeaderLen)
+	binary.LittleEndian.PutUint32(record, directoryHeaderSig)
+	binary.LittleEndian.PutUint32(record[20:], uint32max)
+	binary.LittleEndian.PutUint32(record[24:], uint32max)
+	binary.LittleEndian.PutUint16(record[28:], 1)
+	binary.LittleEndian.PutUint16(record[30:], 8)
+	record = append(record, 'a')
+	record = append(record, 0x01, 0x00, 0x10, 0x00, 0, 0, 0, 0)
+
+	end := func(dirSize uint32) []byte {
+		b := make([]byte, directoryEndLen)
+		binary.LittleEndian.PutUint32(b, directoryEndSig)
+		binary.LittleEndian.PutUint16(b[4:], 1)
+		binary.LittleEndian.PutUint16(b[6:], 1)
+		binary.LittleEndian.PutUint16(b[8:], 1)
+		binary.LittleEndian.PutUint16(b[10:], 1)
+		binary.LittleEndian.PutUint32(b[12:], dirSize)
+		return b
+	}
+
+	archive := filepath.Join(abs, "bad.zip")
+	os.WriteFile(filepath.Join(abs, "bad.z01"), []byte("volume"), 0644)
+
+	tests := map[string][]byte{
+		"extra field":    append(append([]byte{}, record...), end(uint32(len(record)))...),
+		"directory size": append(append([]byte{}, record...), end(uint32max)...),
+	}
+
+	for name, data := range tests {
+		if err := os.WriteFile(archive, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := ArchiveList(archive); !errors.Is(err, zip.ErrFormat) {
+			t.Logf("Expected a format error for the %s, received %v", name, err)
+			t.Fail()
+		}
+	}
+
+	clear()
+}